@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/config"
+	"github.com/Niiaks/Aegis/internal/database"
+	"github.com/Niiaks/Aegis/internal/events"
+	"github.com/Niiaks/Aegis/internal/kafka"
+	"github.com/Niiaks/Aegis/internal/logger"
+	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/internal/outbox"
+	"github.com/Niiaks/Aegis/internal/psp"
+	"github.com/Niiaks/Aegis/internal/reconciliation"
+	"github.com/rs/zerolog"
+)
+
+const usage = `usage: aegis [command]
+
+Run without a command to start the API server.
+
+Commands:
+  reconcile run [--date <YYYY-MM-DD>]
+      Queue a daily reconciliation run for the reconciliation worker. The
+      date defaults to yesterday (UTC); schedule this to reconcile every day.
+
+  reconcile import --file <settlement.csv> --date <YYYY-MM-DD>
+      Reconcile a Paystack settlement export against recorded charges. If the
+      export has a settlement date column, every row must match --date.
+
+  outbox list [--status <status>] [--event-type <type>] [--correlation-id <uuid>] [--limit <n>]
+      List transaction_outbox rows, newest first.
+
+  outbox show <id>
+      Print one outbox row, including its last error and payload.
+
+  outbox reset <id>...
+      Requeue dlq or failed outbox rows as pending with a fresh retry budget.
+
+  outbox replay [--limit <n>] [--topic <topic>] [--dry-run]
+      Republish messages from aegis.dlq to their original topics.
+
+  schemas check
+      Fail if a new event schema version breaks backward compatibility.
+
+  schemas docs [--out <file>]
+      Write the event catalog generated from the event schemas.
+`
+
+// runCommand runs an administrative subcommand and returns the process exit code.
+func runCommand(args []string) int {
+	switch {
+	case len(args) >= 2 && args[0] == "reconcile" && args[1] == "run":
+		return reconcileRun(args[2:])
+	case len(args) >= 2 && args[0] == "reconcile" && args[1] == "import":
+		return reconcileImport(args[2:])
+	case len(args) >= 2 && args[0] == "outbox":
+		return outboxCommand(args[1], args[2:])
+	case len(args) >= 2 && args[0] == "schemas":
+		return schemasCommand(args[1], args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+// commandEnv holds the dependencies shared by CLI subcommands.
+type commandEnv struct {
+	cfg    *config.Config
+	log    zerolog.Logger
+	db     *database.Database
+	ctx    context.Context
+	cancel context.CancelFunc
+	close  func()
+}
+
+func newCommandEnv() (*commandEnv, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	loggerService := logger.New(cfg.Observability)
+	log := logger.NewLoggerWithService(cfg.Observability, loggerService)
+
+	db, err := database.New(cfg, &log, loggerService)
+	if err != nil {
+		loggerService.Shutdown()
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	env := &commandEnv{cfg: cfg, log: log, db: db}
+	env.ctx, env.cancel = context.WithCancel(context.WithValue(context.Background(), middleware.LoggerKey, &env.log))
+	env.close = func() {
+		env.cancel()
+		db.Close()
+		loggerService.Shutdown()
+	}
+	return env, nil
+}
+
+func reconcileRun(args []string) int {
+	fs := flag.NewFlagSet("reconcile run", flag.ContinueOnError)
+	date := fs.String("date", time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly), "day to reconcile (YYYY-MM-DD)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	day, err := time.Parse(time.DateOnly, *date)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconcile run: invalid --date %q, expected YYYY-MM-DD\n", *date)
+		return 2
+	}
+
+	env, err := newCommandEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconcile run: %v\n", err)
+		return 1
+	}
+	defer env.close()
+
+	paystackClient := psp.NewPaystackClient(env.cfg.Paystack.SecretKey, env.cfg.Paystack.BaseURL)
+	service := reconciliation.NewReconciliationService(reconciliation.NewReconciliationRepository(env.db.Pool), paystackClient)
+
+	if err := service.QueueDaily(env.ctx, day); err != nil {
+		env.log.Error().Err(err).Msg("failed to queue reconciliation run")
+		return 1
+	}
+
+	fmt.Printf("queued reconciliation run for %s\n", day.Format(time.DateOnly))
+	return 0
+}
+
+func reconcileImport(args []string) int {
+	fs := flag.NewFlagSet("reconcile import", flag.ContinueOnError)
+	file := fs.String("file", "", "path to the Paystack settlement CSV export")
+	date := fs.String("date", "", "settlement date (YYYY-MM-DD)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" || *date == "" {
+		fmt.Fprintln(os.Stderr, "reconcile import: --file and --date are required")
+		return 2
+	}
+
+	day, err := time.Parse(time.DateOnly, *date)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconcile import: invalid --date %q, expected YYYY-MM-DD\n", *date)
+		return 2
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconcile import: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	env, err := newCommandEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconcile import: %v\n", err)
+		return 1
+	}
+	defer env.close()
+
+	paystackClient := psp.NewPaystackClient(env.cfg.Paystack.SecretKey, env.cfg.Paystack.BaseURL)
+	service := reconciliation.NewReconciliationService(reconciliation.NewReconciliationRepository(env.db.Pool), paystackClient)
+
+	result, err := service.ImportSettlement(env.ctx, f, filepath.Base(*file), day)
+	if err != nil {
+		env.log.Error().Err(err).Msg("settlement import failed")
+		return 1
+	}
+
+	fmt.Printf("run %s: %s (%d lines checked, %d discrepancies)\n", result.Run.ID, result.Run.Status, result.Checked, len(result.Discrepancies))
+	for _, d := range result.Discrepancies {
+		fmt.Printf("  %-24s %-30s expected=%d actual=%d  %s\n", d.ReasonCode, d.PspReference, d.ExpectedAmount, d.ActualAmount, d.Reason)
+	}
+	return 0
+}
+
+func outboxCommand(sub string, args []string) int {
+	switch sub {
+	case "list":
+		return outboxList(args)
+	case "show":
+		return outboxShow(args)
+	case "reset":
+		return outboxReset(args)
+	case "replay":
+		return outboxReplay(args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+func newOutboxService(env *commandEnv) *outbox.OutboxService {
+	return outbox.NewOutboxService(outbox.NewOutboxRepository(env.db.Pool), kafka.NewDLQReplayer(kafka.DefaultConfig(env.cfg.Kafka.Brokers)))
+}
+
+func outboxList(args []string) int {
+	fs := flag.NewFlagSet("outbox list", flag.ContinueOnError)
+	status := fs.String("status", "", "pending, processed, failed or dlq")
+	eventType := fs.String("event-type", "", "filter by event type")
+	correlationID := fs.String("correlation-id", "", "filter by correlation id")
+	limit := fs.Int("limit", 50, "rows to show (max 100)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	env, err := newCommandEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "outbox list: %v\n", err)
+		return 1
+	}
+	defer env.close()
+
+	page, err := newOutboxService(env).ListEvents(env.ctx, &outbox.ListFilter{
+		Status:        *status,
+		EventType:     *eventType,
+		CorrelationID: *correlationID,
+		Limit:         min(max(*limit, 1), 100),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "outbox list: %v\n", err)
+		return 1
+	}
+
+	for _, e := range page.Data {
+		fmt.Printf("%-10d %-10s %-30s retries=%d/%d  %s  %s\n", e.ID, e.Status, e.EventType, e.RetryCount, e.MaxRetries, e.CorrelationID, e.LastError)
+	}
+	return 0
+}
+
+func outboxShow(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "outbox show: expected exactly one id")
+		return 2
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "outbox show: invalid id %q\n", args[0])
+		return 2
+	}
+
+	env, err := newCommandEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "outbox show: %v\n", err)
+		return 1
+	}
+	defer env.close()
+
+	event, err := newOutboxService(env).GetEvent(env.ctx, id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "outbox show: %v\n", err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(event)
+	return 0
+}
+
+func outboxReset(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "outbox reset: expected at least one id")
+		return 2
+	}
+	ids := make([]int64, 0, len(args))
+	for _, a := range args {
+		id, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "outbox reset: invalid id %q\n", a)
+			return 2
+		}
+		ids = append(ids, id)
+	}
+
+	env, err := newCommandEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "outbox reset: %v\n", err)
+		return 1
+	}
+	defer env.close()
+
+	result, err := newOutboxService(env).ResetEvents(env.ctx, ids)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "outbox reset: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("reset %d: %v\n", len(result.Reset), result.Reset)
+	if len(result.Skipped) > 0 {
+		fmt.Printf("skipped %d (missing, pending or processed): %v\n", len(result.Skipped), result.Skipped)
+	}
+	return 0
+}
+
+func outboxReplay(args []string) int {
+	fs := flag.NewFlagSet("outbox replay", flag.ContinueOnError)
+	limit := fs.Int("limit", 0, "stop after this many messages (0 replays until the DLQ is caught up)")
+	topic := fs.String("topic", "", "send every message to this topic instead of its original-topic header")
+	dryRun := fs.Bool("dry-run", false, "list what would be replayed without producing or committing")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	env, err := newCommandEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "outbox replay: %v\n", err)
+		return 1
+	}
+	defer env.close()
+
+	result, err := newOutboxService(env).ReplayDLQ(env.ctx, kafka.ReplayOptions{Limit: *limit, Topic: *topic, DryRun: *dryRun})
+	if result != nil {
+		for _, m := range result.Replayed {
+			fmt.Printf("partition=%d offset=%d key=%s -> %s\n", m.Partition, m.Offset, m.Key, m.Topic)
+		}
+		verb := "replayed"
+		if result.DryRun {
+			verb = "would replay"
+		}
+		fmt.Printf("%s %d messages\n", verb, len(result.Replayed))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "outbox replay: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func schemasCommand(sub string, args []string) int {
+	switch sub {
+	case "check":
+		if err := events.CheckCompatibility(); err != nil {
+			fmt.Fprintf(os.Stderr, "schemas check: incompatible schema changes:\n%v\n", err)
+			return 1
+		}
+		fmt.Printf("%d schemas compatible\n", len(events.Schemas()))
+		return 0
+	case "docs":
+		return schemasDocs(args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+func schemasDocs(args []string) int {
+	fs := flag.NewFlagSet("schemas docs", flag.ContinueOnError)
+	out := fs.String("out", "", "file to write (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "schemas docs: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := events.WriteDocs(w); err != nil {
+		fmt.Fprintf(os.Stderr, "schemas docs: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -11,7 +11,9 @@ import (
 	"github.com/Niiaks/Aegis/internal/database"
 	"github.com/Niiaks/Aegis/internal/kafka"
 	"github.com/Niiaks/Aegis/internal/logger"
+	"github.com/Niiaks/Aegis/internal/outbox"
 	"github.com/Niiaks/Aegis/internal/psp"
+	"github.com/Niiaks/Aegis/internal/reconciliation"
 	"github.com/Niiaks/Aegis/internal/redis"
 	"github.com/Niiaks/Aegis/internal/router"
 	"github.com/Niiaks/Aegis/internal/server"
@@ -22,6 +24,10 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		panic("failed to load config: " + err.Error())
@@ -57,22 +63,30 @@ func main() {
 
 	userRepo := user.NewUserRepository(db.Pool)
 	walletRepo := wallet.NewWalletRepository(db.Pool)
-	transactionRepo := transaction.NewTransactionRepository(db.Pool)
+	transactionRepo := transaction.NewTransactionRepository(db.Pool, redisClient)
+	reconciliationRepo := reconciliation.NewReconciliationRepository(db.Pool)
+	outboxRepo := outbox.NewOutboxRepository(db.Pool)
 
 	userService := user.NewUserService(userRepo)
 	walletService := wallet.NewWalletService(walletRepo)
-	transactionService := transaction.NewTransactionService(transactionRepo, redisClient, paystackClient)
+	transactionService := transaction.NewTransactionService(transactionRepo, paystackClient)
+	reconciliationService := reconciliation.NewReconciliationService(reconciliationRepo, paystackClient)
+	outboxService := outbox.NewOutboxService(outboxRepo, kafka.NewDLQReplayer(kafka.DefaultConfig(cfg.Kafka.Brokers)))
 
 	userHandler := user.NewUserHandler(userService)
 	walletHandler := wallet.NewWalletHandler(walletService)
 	transactionHandler := transaction.NewTransactionHandler(transactionService)
+	reconciliationHandler := reconciliation.NewReconciliationHandler(reconciliationService)
+	outboxHandler := outbox.NewOutboxHandler(outboxService)
 	webhookHandler := webhook.NewWebhookHandler(cfg.Paystack.SecretKey, kafkaProducer, db.Pool)
 
 	handlers := &router.Handlers{
-		User:        userHandler,
-		Wallet:      walletHandler,
-		Transaction: transactionHandler,
-		Webhook:     webhookHandler,
+		User:           userHandler,
+		Wallet:         walletHandler,
+		Transaction:    transactionHandler,
+		Reconciliation: reconciliationHandler,
+		Outbox:         outboxHandler,
+		Webhook:        webhookHandler,
 	}
 
 	r := router.NewRouter(srv, handlers)
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_transactions_parent_transaction_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS parent_transaction_id;
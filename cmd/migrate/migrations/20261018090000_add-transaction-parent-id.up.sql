@@ -0,0 +1,3 @@
+ALTER TABLE transactions ADD COLUMN parent_transaction_id UUID REFERENCES transactions(id) ON DELETE RESTRICT;
+
+CREATE INDEX idx_transactions_parent_transaction_id ON transactions(parent_transaction_id) WHERE parent_transaction_id IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_discrepancies_reason_code;
+DROP INDEX IF EXISTS idx_discrepancies_transaction_id;
+ALTER TABLE discrepancies DROP COLUMN IF EXISTS reason_code;
+ALTER TABLE discrepancies DROP COLUMN IF EXISTS psp_reference;
+ALTER TABLE discrepancies DROP COLUMN IF EXISTS transaction_id;
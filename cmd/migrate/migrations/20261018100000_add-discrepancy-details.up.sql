@@ -0,0 +1,6 @@
+ALTER TABLE discrepancies ADD COLUMN transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL;
+ALTER TABLE discrepancies ADD COLUMN psp_reference VARCHAR(255);
+ALTER TABLE discrepancies ADD COLUMN reason_code VARCHAR(50) NOT NULL DEFAULT 'unknown';
+
+CREATE INDEX idx_discrepancies_transaction_id ON discrepancies(transaction_id);
+CREATE INDEX idx_discrepancies_reason_code ON discrepancies(reason_code);
@@ -0,0 +1,2 @@
+ALTER TABLE reconciliation_runs DROP COLUMN IF EXISTS source_file;
+ALTER TABLE reconciliation_runs DROP COLUMN IF EXISTS source;
@@ -0,0 +1,2 @@
+ALTER TABLE reconciliation_runs ADD COLUMN source VARCHAR(30) NOT NULL DEFAULT 'api' CHECK (source IN ('api', 'settlement_file'));
+ALTER TABLE reconciliation_runs ADD COLUMN source_file TEXT;
@@ -0,0 +1,13 @@
+ALTER TABLE ledger_entries DROP CONSTRAINT IF EXISTS ledger_entries_description_check;
+ALTER TABLE ledger_entries ADD CONSTRAINT ledger_entries_description_check CHECK (description IN ('revenue', 'payout', 'fee', 'refund'));
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_type_check;
+ALTER TABLE transactions ADD CONSTRAINT transactions_type_check CHECK (type IN ('payment_intent', 'payout', 'refund', 'fee'));
+
+DROP INDEX IF EXISTS idx_discrepancies_assigned_to;
+ALTER TABLE discrepancies DROP COLUMN IF EXISTS adjustment_transaction_id;
+ALTER TABLE discrepancies DROP COLUMN IF EXISTS resolved_at;
+ALTER TABLE discrepancies DROP COLUMN IF EXISTS resolved_by;
+ALTER TABLE discrepancies DROP COLUMN IF EXISTS resolution_note;
+ALTER TABLE discrepancies DROP COLUMN IF EXISTS resolution_type;
+ALTER TABLE discrepancies DROP COLUMN IF EXISTS assigned_to;
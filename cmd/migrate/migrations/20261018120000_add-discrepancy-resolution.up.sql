@@ -0,0 +1,15 @@
+ALTER TABLE discrepancies ADD COLUMN assigned_to VARCHAR(255);
+ALTER TABLE discrepancies ADD COLUMN resolution_type VARCHAR(30) CHECK (resolution_type IN ('manual_adjustment', 'psp_error', 'timing_difference', 'duplicate', 'no_action'));
+ALTER TABLE discrepancies ADD COLUMN resolution_note TEXT;
+ALTER TABLE discrepancies ADD COLUMN resolved_by VARCHAR(255);
+ALTER TABLE discrepancies ADD COLUMN resolved_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE discrepancies ADD COLUMN adjustment_transaction_id UUID REFERENCES transactions(id) ON DELETE RESTRICT;
+
+CREATE INDEX idx_discrepancies_assigned_to ON discrepancies(assigned_to);
+
+-- Balancing adjustments are posted through the ledger like any other money movement
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_type_check;
+ALTER TABLE transactions ADD CONSTRAINT transactions_type_check CHECK (type IN ('payment_intent', 'payout', 'refund', 'fee', 'adjustment'));
+
+ALTER TABLE ledger_entries DROP CONSTRAINT IF EXISTS ledger_entries_description_check;
+ALTER TABLE ledger_entries ADD CONSTRAINT ledger_entries_description_check CHECK (description IN ('revenue', 'payout', 'fee', 'refund', 'adjustment'));
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS transaction_outbox_notify ON transaction_outbox;
+DROP FUNCTION IF EXISTS notify_transaction_outbox();
@@ -0,0 +1,12 @@
+CREATE OR REPLACE FUNCTION notify_transaction_outbox() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('transaction_outbox', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Statement level: one notification per insert, however many rows it writes
+CREATE TRIGGER transaction_outbox_notify
+    AFTER INSERT ON transaction_outbox
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION notify_transaction_outbox();
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_transaction_outbox_partition_key_pending;
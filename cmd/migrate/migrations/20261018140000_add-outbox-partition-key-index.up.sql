@@ -0,0 +1 @@
+CREATE INDEX idx_transaction_outbox_partition_key_pending ON transaction_outbox(partition_key, id) WHERE status = 'pending';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transaction_outbox_processed_updated_at;
+DROP TABLE IF EXISTS psp_webhooks_archive;
+DROP TABLE IF EXISTS transaction_outbox_archive;
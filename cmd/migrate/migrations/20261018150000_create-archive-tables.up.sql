@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS transaction_outbox_archive (
+    id BIGINT PRIMARY KEY,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    partition_key VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    correlation_id UUID NOT NULL,
+    retry_count INT NOT NULL,
+    last_error TEXT,
+    next_retry_at TIMESTAMP WITH TIME ZONE,
+    max_retries INT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    archived_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_transaction_outbox_archive_correlation_id ON transaction_outbox_archive(correlation_id);
+CREATE INDEX idx_transaction_outbox_archive_created_at ON transaction_outbox_archive(created_at);
+
+CREATE TABLE IF NOT EXISTS psp_webhooks_archive (
+    id UUID PRIMARY KEY,
+    event_id VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    archived_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_psp_webhooks_archive_event_id ON psp_webhooks_archive(event_id);
+CREATE INDEX idx_psp_webhooks_archive_created_at ON psp_webhooks_archive(created_at);
+
+-- Retention scans processed rows by age
+CREATE INDEX idx_transaction_outbox_processed_updated_at ON transaction_outbox(updated_at) WHERE status = 'processed';
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS processed_messages (
+    consumer_group VARCHAR(255) NOT NULL,
+    topic VARCHAR(255) NOT NULL,
+    kafka_partition INT NOT NULL,
+    kafka_offset BIGINT NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (consumer_group, topic, kafka_partition, kafka_offset)
+);
+
+CREATE INDEX idx_processed_messages_processed_at ON processed_messages(processed_at);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS wallets_fence_token_check ON wallets;
+DROP FUNCTION IF EXISTS check_wallet_fence_token();
+ALTER TABLE wallets DROP COLUMN IF EXISTS fence_token;
@@ -0,0 +1,20 @@
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS fence_token BIGINT NOT NULL DEFAULT 0;
+
+-- Rejects a write carrying a fencing token older than the last one stored, i.e.
+-- one made under a Redis wallet lock that has since been taken over. Updates
+-- that leave fence_token alone are not affected.
+CREATE OR REPLACE FUNCTION check_wallet_fence_token() RETURNS trigger AS $$
+BEGIN
+    IF NEW.fence_token < OLD.fence_token THEN
+        RAISE EXCEPTION 'stale fencing token % for wallet %, current is %',
+            NEW.fence_token, OLD.id, OLD.fence_token
+            USING ERRCODE = 'AG001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallets_fence_token_check
+    BEFORE UPDATE OF fence_token ON wallets
+    FOR EACH ROW
+    EXECUTE FUNCTION check_wallet_fence_token();
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS reconciliation_runs_daily_unique;
@@ -0,0 +1,20 @@
+-- Fold repeated API runs for the same day into the earliest one before
+-- making the day unique.
+CREATE TEMP TABLE kept_runs AS
+SELECT DISTINCT ON (run_date) id, run_date
+FROM reconciliation_runs
+WHERE source = 'api'
+ORDER BY run_date, created_at, id;
+
+UPDATE discrepancies d
+SET reconciliation_run_id = k.id
+FROM reconciliation_runs r
+JOIN kept_runs k ON k.run_date = r.run_date
+WHERE d.reconciliation_run_id = r.id AND r.source = 'api' AND r.id <> k.id;
+
+DELETE FROM reconciliation_runs
+WHERE source = 'api' AND id NOT IN (SELECT id FROM kept_runs);
+
+DROP TABLE kept_runs;
+
+CREATE UNIQUE INDEX reconciliation_runs_daily_unique ON reconciliation_runs(run_date) WHERE source = 'api';
@@ -0,0 +1,6 @@
+-- Best effort: lines posted to seller wallets go back to the seller's user ID.
+-- System account lines keep their wallet IDs.
+UPDATE ledger_entries le
+SET account_id = w.user_id, updated_at = NOW()
+FROM wallets w
+WHERE w.id = le.account_id AND w.user_id <> '00000000-0000-0000-0000-000000000000';
@@ -0,0 +1,15 @@
+-- Seller ledger lines used to carry the seller's user ID as account_id. They
+-- now carry the wallet ID, as system account lines always did. Point old
+-- lines at the seller's wallet in the transaction's currency, preferring the
+-- settlement wallet every new line is posted to.
+UPDATE ledger_entries le
+SET account_id = m.wallet_id, updated_at = NOW()
+FROM (
+    SELECT DISTINCT ON (le.id) le.id AS entry_id, w.id AS wallet_id
+    FROM ledger_entries le
+    JOIN transactions t ON t.id = le.transaction_id
+    JOIN wallets w ON w.user_id = le.account_id AND w.currency = t.currency
+    WHERE NOT EXISTS (SELECT 1 FROM wallets x WHERE x.id = le.account_id)
+    ORDER BY le.id, (w.type = 'settlement') DESC, w.created_at
+) m
+WHERE le.id = m.entry_id;
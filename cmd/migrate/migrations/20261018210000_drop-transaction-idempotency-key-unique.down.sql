@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_transactions_idempotency_key;
+ALTER TABLE transactions ADD CONSTRAINT transactions_idempotency_key_unique UNIQUE (idempotency_key);
@@ -0,0 +1,5 @@
+-- Idempotency keys are scoped to (key, path), as in Redis and idempotency_keys.
+-- The transactions column only records the key: idempotency_keys, written in
+-- the same transaction, is what rejects a repeat.
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_idempotency_key_unique;
+CREATE INDEX IF NOT EXISTS idx_transactions_idempotency_key ON transactions(idempotency_key);
@@ -37,7 +37,7 @@ func main() {
 	}
 	defer kProducer.Close()
 
-	relay := outbox.NewRelay(db.Pool, kProducer, &log)
+	relay := outbox.NewRelay(db.Pool, kProducer, &log, cfg.Outbox)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
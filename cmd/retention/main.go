@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Niiaks/Aegis/internal/config"
+	"github.com/Niiaks/Aegis/internal/database"
+	"github.com/Niiaks/Aegis/internal/idempotency"
+	"github.com/Niiaks/Aegis/internal/logger"
+	"github.com/Niiaks/Aegis/internal/retention"
+)
+
+func main() {
+	once := flag.Bool("once", false, "run a single retention pass and exit")
+	dryRun := flag.Bool("dry-run", false, "only count the rows that would be archived or deleted")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		panic("failed to load config: " + err.Error())
+	}
+	if *dryRun {
+		cfg.Retention.DryRun = true
+	}
+
+	loggerService := logger.New(cfg.Observability)
+	defer loggerService.Shutdown()
+	log := logger.NewLoggerWithService(cfg.Observability, loggerService)
+
+	log.Info().Msg("Starting Retention Service...")
+
+	db, err := database.New(cfg, &log, loggerService)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to initialize database")
+	}
+	defer db.Close()
+
+	job := retention.NewJob(db.Pool, &log, loggerService.GetApplication(), cfg.Retention)
+	// Expired idempotency keys are deleted outright; a dry run leaves them.
+	purge := idempotency.NewPurgeJob(db.Pool, &log, loggerService.GetApplication(), cfg.Idempotency)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if *once {
+		if _, err := job.Run(ctx); err != nil {
+			log.Fatal().Err(err).Msg("retention run failed")
+		}
+		if !cfg.Retention.DryRun {
+			if _, err := purge.Run(ctx); err != nil {
+				log.Fatal().Err(err).Msg("idempotency purge failed")
+			}
+		}
+		return
+	}
+
+	go func() {
+		if err := job.Start(ctx); err != nil {
+			log.Error().Err(err).Msg("Retention service stopped with error")
+		}
+	}()
+	if !cfg.Retention.DryRun {
+		go func() {
+			if err := purge.Start(ctx); err != nil {
+				log.Error().Err(err).Msg("Idempotency purge stopped with error")
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Info().Msg("Shutting down Retention Service...")
+	cancel()
+
+	log.Info().Msg("Retention Service shutdown complete")
+}
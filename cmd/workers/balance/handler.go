@@ -2,12 +2,13 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"time"
+	"errors"
 
 	"github.com/Niiaks/Aegis/internal/database"
+	"github.com/Niiaks/Aegis/internal/events"
 	"github.com/Niiaks/Aegis/internal/kafka"
 	"github.com/Niiaks/Aegis/internal/redis"
+	"github.com/Niiaks/Aegis/internal/wallet"
 	"github.com/Niiaks/Aegis/pkg/types"
 	"github.com/rs/zerolog"
 )
@@ -17,13 +18,17 @@ func balanceHandler(db *database.Database, redis *redis.Client, log *zerolog.Log
 		log.Info().Str("topic", msg.Topic).Int64("offset", msg.Offset).Msg("Processing balance update")
 
 		var event types.BalanceUpdateEvent
-		if err := json.Unmarshal(msg.Value, &event); err != nil {
+		_, err := events.Decode(msg, &event)
+		if errors.Is(err, events.ErrObsolete) {
+			log.Warn().Err(err).Int64("offset", msg.Offset).Msg("Skipping obsolete balance update payload")
+			return nil
+		}
+		if err != nil {
 			log.Error().Err(err).Msg("Failed to unmarshal balance update message")
 			return err
 		}
 
 		// Validation: skip old messages with full webhook JSON or empty values
-		// Remove later
 		if event.UserID == "" || event.NetAmount <= 0 {
 			log.Warn().
 				Int64("offset", msg.Offset).
@@ -34,25 +39,47 @@ func balanceHandler(db *database.Database, redis *redis.Client, log *zerolog.Log
 		}
 
 		// Acquire lock on user wallet
-		lock, err := redis.AcquireLock(ctx, "wallet:"+event.UserID, 10*time.Second)
+		lock, err := wallet.Lock(ctx, db.Pool, redis, event.UserID)
 		if err != nil {
 			log.Error().Err(err).Str("user_id", event.UserID).Msg("Failed to acquire wallet lock")
 			return err
 		}
 		defer lock.Release(ctx)
 
+		tx, err := db.Pool.Begin(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to begin transaction")
+			return err
+		}
+		// locked_balance also holds payout and refund reservations, so its
+		// size cannot tell a redelivered event apart: record the message in
+		// the same transaction as the move instead.
+		first, err := kafka.MarkProcessed(ctx, tx, msg)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to record processed message")
+			tx.Rollback(ctx)
+			return err
+		}
+		if !first {
+			log.Info().Int64("offset", msg.Offset).Msg("Message already processed, skipping")
+			return tx.Rollback(ctx)
+		}
+
 		// Atomically move funds from locked_balance to balance
-		// We use a check on locked_balance >= amount to ensure we don't go negative (idempotency/safety check)
-		res, err := db.Pool.Exec(ctx, `
+		// We use a check on locked_balance >= amount to ensure we don't go negative
+		res, err := tx.Exec(ctx, `
 			UPDATE wallets 
 			SET locked_balance = locked_balance - $1, 
 				balance = balance + $1, 
+				fence_token = $4,
 				updated_at = NOW() 
-			WHERE user_id = $2 AND locked_balance >= $1`,
-			event.NetAmount, event.UserID)
+			WHERE user_id = $2 AND currency = $3 AND type = 'settlement' AND locked_balance >= $1`,
+			event.NetAmount, event.UserID, event.Currency, lock.Token())
 
 		if err != nil {
+			err = wallet.FenceError(err)
 			log.Error().Err(err).Str("user_id", event.UserID).Msg("Failed to finalize balance move")
+			tx.Rollback(ctx)
 			return err
 		}
 
@@ -62,6 +89,6 @@ func balanceHandler(db *database.Database, redis *redis.Client, log *zerolog.Log
 			log.Info().Str("user_id", event.UserID).Int64("amount", event.NetAmount).Msg("Successfully finalized balance move")
 		}
 
-		return nil
+		return tx.Commit(ctx)
 	}
 }
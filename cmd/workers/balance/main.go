@@ -5,9 +5,11 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Niiaks/Aegis/internal/config"
 	"github.com/Niiaks/Aegis/internal/database"
+	"github.com/Niiaks/Aegis/internal/health"
 	"github.com/Niiaks/Aegis/internal/kafka"
 	"github.com/Niiaks/Aegis/internal/logger"
 	"github.com/Niiaks/Aegis/internal/redis"
@@ -38,7 +40,15 @@ func main() {
 	defer redis.Close()
 
 	// Initialize Webhook Worker
-	consumer, err := kafka.NewConsumer(kafka.DefaultConfig(cfg.Kafka.Brokers), kafka.GroupBalanceWorker, kafka.TopicBalanceUpdate)
+	kafkaCfg := kafka.DefaultConfig(cfg.Kafka.Brokers)
+	kafkaCfg.ConcurrentPartitions = cfg.Kafka.ConcurrentPartitions
+	kafkaCfg.Logger = &log
+	nrApp := loggerService.GetApplication()
+	router := kafka.NewRouter()
+	router.Use(kafka.Logging(&log), kafka.Tracing(nrApp), kafka.Metrics(nrApp), kafka.Recover(), kafka.Timeout(30*time.Second))
+	router.HandleTopic(kafka.TopicBalanceUpdate, balanceHandler(db, redis, &log))
+
+	consumer, err := kafka.NewConsumer(kafkaCfg, kafka.GroupBalanceWorker, router.Topics()...)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to initialize kafka consumer")
 	}
@@ -46,11 +56,16 @@ func main() {
 	defer cancel()
 
 	go func() {
-		if err := consumer.Run(ctx, balanceHandler(db, redis, &log)); err != nil {
+		if err := consumer.Run(ctx, router.Handler()); err != nil {
 			log.Error().Err(err).Msg("Relay service stopped with error")
 		}
 	}()
 
+	healthServer := health.NewServer(cfg.Worker.HealthAddrOr(":8082"), consumer, cfg.Observability.HealthChecks, &log, nrApp)
+	healthServer.AddCheck("database", db.Pool.Ping)
+	healthServer.AddCheck("redis", redis.Ping)
+	healthServer.Start(ctx)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -58,5 +73,11 @@ func main() {
 	log.Info().Msg("Shutting down Balance Worker...")
 	cancel()
 
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := healthServer.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("failed to shut down health server")
+	}
+
 	log.Info().Msg("Balance Worker shutdown complete")
 }
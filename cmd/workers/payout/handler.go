@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Niiaks/Aegis/internal/database"
+	"github.com/Niiaks/Aegis/internal/events"
+	"github.com/Niiaks/Aegis/internal/kafka"
+	"github.com/Niiaks/Aegis/internal/payout"
+	"github.com/Niiaks/Aegis/internal/psp"
+	"github.com/Niiaks/Aegis/pkg/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+func payoutHandler(db *database.Database, settler *payout.Settler, paystack psp.Client, log *zerolog.Logger) kafka.Handler {
+	return func(ctx context.Context, msg *kafka.Message) error {
+		log.Info().Str("topic", msg.Topic).Int64("offset", msg.Offset).Msg("Processing payout")
+
+		var event types.PayoutEvent
+		if _, err := events.Decode(msg, &event); err != nil {
+			log.Error().Err(err).Msg("Failed to unmarshal payout message")
+			return err
+		}
+
+		// Only pending payouts without a transfer are sent to Paystack. A
+		// redelivered message for a payout that already completed or failed,
+		// or whose transfer is in flight, is acknowledged and dropped.
+		var status, transferCode string
+		err := db.Pool.QueryRow(ctx, "SELECT status, COALESCE(psp_reference, '') FROM transactions WHERE id = $1 AND type = 'payout'", event.TransactionID).Scan(&status, &transferCode)
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Warn().Str("transaction_id", event.TransactionID).Msg("Payout transaction not found, skipping")
+			return nil
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to load payout transaction")
+			return err
+		}
+		if status != "pending" {
+			log.Info().Str("transaction_id", event.TransactionID).Str("status", status).Msg("Payout already processed, skipping")
+			return nil
+		}
+		if transferCode != "" {
+			log.Info().Str("transaction_id", event.TransactionID).Str("transfer_code", transferCode).Msg("Transfer already initiated, waiting for its outcome")
+			return nil
+		}
+
+		// An earlier attempt may have reached Paystack and failed before the
+		// transfer code was recorded, so look the reference up before sending.
+		transfer, err := paystack.VerifyTransfer(ctx, event.TransactionID)
+		if errors.Is(err, psp.ErrNotFound) {
+			transfer, err = createTransfer(ctx, paystack, &event)
+			if errors.Is(err, psp.ErrRequestRejected) {
+				log.Error().Err(err).Str("transaction_id", event.TransactionID).Msg("Paystack rejected transfer, releasing reserved funds")
+				return settler.Fail(ctx, event.TransactionID, err.Error())
+			}
+		}
+		if err != nil {
+			log.Error().Err(err).Str("transaction_id", event.TransactionID).Msg("Failed to create transfer")
+			return err // Retry later
+		}
+
+		return settler.Apply(ctx, event.TransactionID, transfer.Data.TransferCode, transfer.Data.Status)
+	}
+}
+
+// createTransfer sends the payout to Paystack. The transaction ID doubles as
+// the transfer reference, so Paystack rejects a second transfer for the same
+// payout; that rejection is resolved to the transfer already there.
+func createTransfer(ctx context.Context, paystack psp.Client, event *types.PayoutEvent) (*types.TransferResponse, error) {
+	transfer, err := paystack.CreateTransfer(ctx, &types.TransferRequest{
+		Amount:    event.Amount,
+		Recipient: event.Recipient,
+		Reason:    event.Reason,
+		Currency:  event.Currency,
+		Reference: event.TransactionID,
+	})
+	if !errors.Is(err, psp.ErrRequestRejected) {
+		return transfer, err
+	}
+
+	existing, verifyErr := paystack.VerifyTransfer(ctx, event.TransactionID)
+	if verifyErr == nil {
+		return existing, nil
+	}
+	if !errors.Is(verifyErr, psp.ErrNotFound) {
+		// The rejection may be for a duplicate reference; retry rather than
+		// release funds that may have been sent.
+		return nil, verifyErr
+	}
+	return nil, err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/config"
+	"github.com/Niiaks/Aegis/internal/database"
+	"github.com/Niiaks/Aegis/internal/events"
+	"github.com/Niiaks/Aegis/internal/health"
+	"github.com/Niiaks/Aegis/internal/kafka"
+	"github.com/Niiaks/Aegis/internal/logger"
+	"github.com/Niiaks/Aegis/internal/payout"
+	"github.com/Niiaks/Aegis/internal/psp"
+	"github.com/Niiaks/Aegis/internal/redis"
+)
+
+func main() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		panic("failed to load config: " + err.Error())
+	}
+
+	loggerService := logger.New(cfg.Observability)
+	defer loggerService.Shutdown()
+	log := logger.NewLoggerWithService(cfg.Observability, loggerService)
+
+	log.Info().Msg("Starting Payout Worker...")
+
+	db, err := database.New(cfg, &log, loggerService)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to initialize database")
+	}
+	defer db.Close()
+
+	redis, err := redis.New(&log, &cfg.Redis)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to initialize redis")
+	}
+	defer redis.Close()
+
+	paystackClient := psp.NewPaystackClient(cfg.Paystack.SecretKey, cfg.Paystack.BaseURL)
+	settler := payout.NewSettler(db.Pool, redis, &log, events.SourcePayoutWorker)
+
+	kafkaCfg := kafka.DefaultConfig(cfg.Kafka.Brokers)
+	kafkaCfg.ConcurrentPartitions = cfg.Kafka.ConcurrentPartitions
+	kafkaCfg.Logger = &log
+	nrApp := loggerService.GetApplication()
+	router := kafka.NewRouter()
+	router.Use(kafka.Logging(&log), kafka.Tracing(nrApp), kafka.Metrics(nrApp), kafka.Recover(), kafka.Timeout(time.Minute))
+	router.HandleTopic(kafka.TopicPayoutPending, payoutHandler(db, settler, paystackClient, &log))
+
+	consumer, err := kafka.NewConsumer(kafkaCfg, kafka.GroupPayoutWorker, router.Topics()...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to initialize kafka consumer")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := consumer.Run(ctx, router.Handler()); err != nil {
+			log.Error().Err(err).Msg("Payout worker stopped with error")
+		}
+	}()
+
+	verifier := payout.NewVerifier(db.Pool, settler, paystackClient, &log, cfg.Payout)
+	go func() {
+		if err := verifier.Start(ctx); err != nil {
+			log.Error().Err(err).Msg("Payout verifier stopped with error")
+		}
+	}()
+
+	healthServer := health.NewServer(cfg.Worker.HealthAddrOr(":8083"), consumer, cfg.Observability.HealthChecks, &log, nrApp)
+	healthServer.AddCheck("database", db.Pool.Ping)
+	healthServer.AddCheck("redis", redis.Ping)
+	healthServer.Start(ctx)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Info().Msg("Shutting down Payout Worker...")
+	cancel()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := healthServer.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("failed to shut down health server")
+	}
+
+	log.Info().Msg("Payout Worker shutdown complete")
+}
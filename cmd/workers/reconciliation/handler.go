@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/events"
+	"github.com/Niiaks/Aegis/internal/kafka"
+	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/internal/reconciliation"
+	"github.com/Niiaks/Aegis/pkg/types"
+	"github.com/rs/zerolog"
+)
+
+func reconciliationHandler(service *reconciliation.ReconciliationService, log *zerolog.Logger) kafka.Handler {
+	return func(ctx context.Context, msg *kafka.Message) error {
+		log.Info().Str("topic", msg.Topic).Int64("offset", msg.Offset).Msg("Processing reconciliation job")
+
+		var job types.ReconciliationJob
+		if _, err := events.Decode(msg, &job); err != nil {
+			log.Error().Err(err).Msg("Failed to decode reconciliation job")
+			return err
+		}
+
+		day, err := time.Parse(time.DateOnly, job.RunDate)
+		if err != nil {
+			log.Error().Err(err).Str("run_date", job.RunDate).Msg("Invalid run date, skipping job")
+			return nil
+		}
+
+		jobLog := log.With().Str("run_date", job.RunDate).Str("request_id", middleware.GetRequestIDFromContext(ctx)).Logger()
+		ctx = context.WithValue(ctx, middleware.LoggerKey, &jobLog)
+
+		result, err := service.RunDaily(ctx, day)
+		if err != nil {
+			jobLog.Error().Err(err).Msg("Reconciliation run failed")
+			return err
+		}
+
+		jobLog.Info().Str("run_id", result.Run.ID.String()).Str("status", result.Run.Status).Msg("Reconciliation job complete")
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/config"
+	"github.com/Niiaks/Aegis/internal/database"
+	"github.com/Niiaks/Aegis/internal/health"
+	"github.com/Niiaks/Aegis/internal/kafka"
+	"github.com/Niiaks/Aegis/internal/logger"
+	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/internal/psp"
+	"github.com/Niiaks/Aegis/internal/reconciliation"
+)
+
+func main() {
+	runDate := flag.String("date", "", "reconcile a single day (YYYY-MM-DD) and exit instead of consuming jobs")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		panic("failed to load config: " + err.Error())
+	}
+
+	loggerService := logger.New(cfg.Observability)
+	defer loggerService.Shutdown()
+	log := logger.NewLoggerWithService(cfg.Observability, loggerService)
+
+	log.Info().Msg("Starting Reconciliation Worker...")
+
+	db, err := database.New(cfg, &log, loggerService)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to initialize database")
+	}
+	defer db.Close()
+
+	paystackClient := psp.NewPaystackClient(cfg.Paystack.SecretKey, cfg.Paystack.BaseURL)
+	service := reconciliation.NewReconciliationService(reconciliation.NewReconciliationRepository(db.Pool), paystackClient)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if *runDate != "" {
+		day, err := time.Parse(time.DateOnly, *runDate)
+		if err != nil {
+			log.Fatal().Err(err).Str("date", *runDate).Msg("invalid -date, expected YYYY-MM-DD")
+		}
+		if _, err := service.RunDaily(context.WithValue(ctx, middleware.LoggerKey, &log), day); err != nil {
+			log.Fatal().Err(err).Msg("reconciliation run failed")
+		}
+		return
+	}
+
+	kafkaCfg := kafka.DefaultConfig(cfg.Kafka.Brokers)
+	kafkaCfg.ConcurrentPartitions = cfg.Kafka.ConcurrentPartitions
+	kafkaCfg.Logger = &log
+	nrApp := loggerService.GetApplication()
+	router := kafka.NewRouter()
+	router.Use(kafka.Logging(&log), kafka.Tracing(nrApp), kafka.Metrics(nrApp), kafka.Recover(), kafka.Timeout(30*time.Minute))
+	router.HandleTopic(kafka.TopicReconciliationJob, reconciliationHandler(service, &log))
+
+	consumer, err := kafka.NewConsumer(kafkaCfg, kafka.GroupReconciliation, router.Topics()...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to initialize kafka consumer")
+	}
+
+	go func() {
+		if err := consumer.Run(ctx, router.Handler()); err != nil {
+			log.Error().Err(err).Msg("Reconciliation worker stopped with error")
+		}
+	}()
+
+	healthServer := health.NewServer(cfg.Worker.HealthAddrOr(":8084"), consumer, cfg.Observability.HealthChecks, &log, nrApp)
+	healthServer.AddCheck("database", db.Pool.Ping)
+	healthServer.Start(ctx)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Info().Msg("Shutting down Reconciliation Worker...")
+	cancel()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := healthServer.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("failed to shut down health server")
+	}
+
+	log.Info().Msg("Reconciliation Worker shutdown complete")
+}
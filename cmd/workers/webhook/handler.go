@@ -2,26 +2,47 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Niiaks/Aegis/internal/database"
+	"github.com/Niiaks/Aegis/internal/events"
 	"github.com/Niiaks/Aegis/internal/kafka"
 	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/internal/outbox"
+	"github.com/Niiaks/Aegis/internal/payout"
 	"github.com/Niiaks/Aegis/internal/redis"
+	"github.com/Niiaks/Aegis/internal/transaction"
+	"github.com/Niiaks/Aegis/internal/wallet"
 	"github.com/Niiaks/Aegis/pkg/constants"
 	"github.com/Niiaks/Aegis/pkg/types"
 	"github.com/rs/zerolog"
 )
 
 const PlatformFee int64 = 30 // 30% of the amount (store in config)
-func webhookHandler(db *database.Database, redis *redis.Client, log *zerolog.Logger) kafka.Handler {
+func webhookHandler(db *database.Database, redis *redis.Client, payouts *payout.Settler, refunds *transaction.RefundVerifier, log *zerolog.Logger) kafka.Handler {
 	return func(ctx context.Context, msg *kafka.Message) error {
 		log.Info().Str("topic", msg.Topic).Int64("offset", msg.Offset).Msg("Processing webhook")
 
+		var head struct {
+			Event string `json:"event"`
+		}
+		env, err := events.Decode(msg, &head)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to unmarshal webhook message")
+			return err
+		}
+		if strings.HasPrefix(head.Event, "refund.") {
+			return handleRefundWebhook(ctx, db, refunds, log, env)
+		}
+
 		var event types.PaystackWebhookEvent
-		if err := json.Unmarshal(msg.Value, &event); err != nil {
+		if err := json.Unmarshal(env.Data, &event); err != nil {
 			log.Error().Err(err).Msg("Failed to unmarshal webhook message")
 			return err
 		}
@@ -31,10 +52,10 @@ func webhookHandler(db *database.Database, redis *redis.Client, log *zerolog.Log
 			log.Info().Str("reference", event.Data.Reference).Msg("Webhook already processed,skipping")
 			return nil
 		}
-		idStr := fmt.Sprintf("%d", event.Data.ID)
+		idStr := webhookEventID(&event)
 		//if processed is empty, we insert the webhook into the database
 		if proccessed == "" {
-			_, err := db.Pool.Exec(ctx, "INSERT INTO psp_webhooks (event_id, payload, updated_at, created_at) VALUES ($1, $2, $3, $4)", idStr, msg.Value, time.Now(), time.Now())
+			_, err := db.Pool.Exec(ctx, "INSERT INTO psp_webhooks (event_id, payload, updated_at, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (event_id) DO NOTHING", idStr, env.Data, time.Now(), time.Now())
 			if err != nil {
 				log.Error().Err(err).Msg("Failed to insert webhook into database")
 				return err
@@ -42,8 +63,21 @@ func webhookHandler(db *database.Database, redis *redis.Client, log *zerolog.Log
 			redis.SetIdempotencyKey(ctx, event.Data.Reference, 30*time.Minute)
 		}
 
+		if status, ok := strings.CutPrefix(event.Event, "transfer."); ok {
+			// Transfers are referenced by their payout's transaction ID.
+			err := payouts.Apply(ctx, event.Data.Reference, event.Data.TransferCode, status)
+			if errors.Is(err, payout.ErrNotFound) {
+				log.Warn().Str("reference", event.Data.Reference).Msg("Transfer webhook for unknown payout, skipping")
+				return setWebhookStatus(ctx, db.Pool, log, idStr, "error")
+			}
+			if err != nil {
+				return err
+			}
+			return setWebhookStatus(ctx, db.Pool, log, idStr, "processed")
+		}
+
 		// Acquire distributed lock on user wallet
-		lock, err := redis.AcquireLock(ctx, "wallet:"+event.Data.Metadata.UserID, 10*time.Second)
+		lock, err := wallet.Lock(ctx, db.Pool, redis, event.Data.Metadata.UserID)
 		if err != nil {
 			log.Error().Err(err).Str("user_id", event.Data.Metadata.UserID).Msg("Failed to acquire wallet lock")
 			return err // Retry later
@@ -55,6 +89,35 @@ func webhookHandler(db *database.Database, redis *redis.Client, log *zerolog.Log
 			log.Error().Err(err).Msg("Failed to begin transaction")
 			return err
 		}
+		first, err := kafka.MarkProcessed(ctx, tx, msg)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to record processed message")
+			tx.Rollback(ctx)
+			return err
+		}
+		if !first {
+			log.Info().Int64("offset", msg.Offset).Msg("Message already processed, skipping")
+			return tx.Rollback(ctx)
+		}
+		// Completing the charge first makes the credit below conditional on it:
+		// a re-delivered webhook arrives at a new offset, after its Redis key
+		// and stored row may be gone, and must not credit the wallets again.
+		tag, err := tx.Exec(ctx, `UPDATE transactions SET psp_reference = $1, status = 'completed', updated_at = NOW() WHERE id = $2 AND status = 'pending'`,
+			event.Data.Reference, event.Data.Metadata.TransactionID)
+		if err != nil {
+			log.Error().Err(err).Msg("Transaction: Failed to mark as completed")
+			tx.Rollback(ctx)
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			log.Warn().Str("transaction_id", event.Data.Metadata.TransactionID).Str("reference", event.Data.Reference).Msg("Charge is not pending, skipping credit")
+			if err := setWebhookStatus(ctx, tx, log, idStr, "processed"); err != nil {
+				tx.Rollback(ctx)
+				return err
+			}
+			return tx.Commit(ctx)
+		}
+
 		// insert into wallets(platform,seller,other), insert into ledger_entries
 		// credit this, debit this so that the ledger consumer can process the ledger
 
@@ -63,9 +126,13 @@ func webhookHandler(db *database.Database, redis *redis.Client, log *zerolog.Log
 		platformAmount := event.Data.Amount * PlatformFee / 100
 
 		// Update seller wallet and get new balance
+		var sellerWalletID string
 		var sellerBalanceAfter int64
-		err = tx.QueryRow(ctx, "UPDATE wallets SET locked_balance = locked_balance + $1 WHERE user_id = $2 RETURNING locked_balance", netAmount, event.Data.Metadata.UserID).Scan(&sellerBalanceAfter)
+		// The fencing token rejects this write if the lock expired and another
+		// worker has since written to the wallet under a newer one.
+		err = tx.QueryRow(ctx, "UPDATE wallets SET locked_balance = locked_balance + $1, fence_token = $4 WHERE user_id = $2 AND currency = $3 AND type = 'settlement' RETURNING id, locked_balance", netAmount, event.Data.Metadata.UserID, event.Data.Currency, lock.Token()).Scan(&sellerWalletID, &sellerBalanceAfter)
 		if err != nil {
+			err = wallet.FenceError(err)
 			log.Error().Err(err).Msg("Wallet: Failed to update seller wallet")
 			tx.Rollback(ctx)
 			return err
@@ -98,7 +165,7 @@ func webhookHandler(db *database.Database, redis *redis.Client, log *zerolog.Log
 		}
 
 		// Credit seller for net amount
-		_, err = tx.Exec(ctx, "INSERT INTO ledger_entries (transaction_id,account_id,debit,credit,balance_after,description,updated_at,created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)", event.Data.Metadata.TransactionID, event.Data.Metadata.UserID, 0, netAmount, sellerBalanceAfter, "revenue", time.Now(), time.Now())
+		_, err = tx.Exec(ctx, "INSERT INTO ledger_entries (transaction_id,account_id,debit,credit,balance_after,description,updated_at,created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)", event.Data.Metadata.TransactionID, sellerWalletID, 0, netAmount, sellerBalanceAfter, "revenue", time.Now(), time.Now())
 		if err != nil {
 			log.Error().Err(err).Msg("Ledger: Failed to insert seller ledger entry")
 			tx.Rollback(ctx)
@@ -113,14 +180,6 @@ func webhookHandler(db *database.Database, redis *redis.Client, log *zerolog.Log
 			return err
 		}
 
-		_, err = tx.Exec(ctx, `UPDATE transactions SET psp_reference = $1, status = 'completed', updated_at = NOW() WHERE id = $2`,
-			event.Data.Reference, event.Data.Metadata.TransactionID)
-		if err != nil {
-			log.Error().Err(err).Msg("Transaction: Failed to mark as completed")
-			tx.Rollback(ctx)
-			return err
-		}
-
 		// Prepare balance update payload
 		updateEvent := types.BalanceUpdateEvent{
 			TransactionID: event.Data.Metadata.TransactionID,
@@ -128,26 +187,76 @@ func webhookHandler(db *database.Database, redis *redis.Client, log *zerolog.Log
 			NetAmount:     netAmount,
 			Currency:      event.Data.Currency,
 		}
-		payloadBytes, err := json.Marshal(updateEvent)
-		if err != nil {
-			log.Error().Err(err).Msg("Outbox: Failed to marshal balance update event")
-			tx.Rollback(ctx)
-			return err
-		}
-
 		requestID := middleware.GetRequestIDFromContext(ctx)
 		if requestID == "" {
-			requestID = fmt.Sprintf("gen-%s", time.Now().Format("20060102150405")) // Fallback if context is lost
-			log.Warn().Str("new_id", requestID).Msg("Request ID missing in context, generated fallback")
+			log.Warn().Msg("Request ID missing in context, outbox will generate a correlation ID")
 		}
 		log.Info().Str("request_id", requestID).Msg("Using Correlation ID")
 
-		_, err = tx.Exec(ctx, "INSERT INTO transaction_outbox (event_type, payload, partition_key,correlation_id, status, updated_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)", kafka.EventLedgerEntryCreated, payloadBytes, event.Data.Metadata.UserID, requestID, "pending", time.Now(), time.Now())
+		err = outbox.Write(ctx, tx, outbox.Event{
+			Type:          kafka.EventLedgerEntryCreated,
+			Source:        events.SourceWebhookWorker,
+			PartitionKey:  event.Data.Metadata.UserID,
+			CorrelationID: requestID,
+			Data:          updateEvent,
+		})
 		if err != nil {
 			log.Error().Err(err).Msg("Outbox: Failed to insert ledger entry created event")
 			tx.Rollback(ctx)
 			return err
 		}
+
+		if err := setWebhookStatus(ctx, tx, log, idStr, "processed"); err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
 		return tx.Commit(ctx)
 	}
 }
+
+// handleRefundWebhook stores a refund webhook and verifies the pending refunds
+// of the charge it names. Paystack's fetch endpoint, not the webhook body, is
+// trusted for the outcome.
+func handleRefundWebhook(ctx context.Context, db *database.Database, refunds *transaction.RefundVerifier, log *zerolog.Logger, env *events.Envelope) error {
+	var event types.PaystackRefundWebhookEvent
+	if err := json.Unmarshal(env.Data, &event); err != nil {
+		log.Error().Err(err).Msg("Failed to unmarshal refund webhook")
+		return err
+	}
+
+	// Refund webhooks carry no ID of their own; a redelivery has the same body.
+	sum := sha256.Sum256(env.Data)
+	eventID := event.Event + ":" + hex.EncodeToString(sum[:])
+	_, err := db.Pool.Exec(ctx, "INSERT INTO psp_webhooks (event_id, payload, updated_at, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (event_id) DO NOTHING", eventID, env.Data, time.Now(), time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to insert webhook into database")
+		return err
+	}
+
+	if err := refunds.VerifyCharge(ctx, event.Data.TransactionReference); err != nil {
+		log.Error().Err(err).Str("transaction_reference", event.Data.TransactionReference).Msg("Failed to verify refunds")
+		return err
+	}
+	return setWebhookStatus(ctx, db.Pool, log, eventID, "processed")
+}
+
+// setWebhookStatus moves a stored webhook out of received once it has been
+// handled. Only processed rows are pruned by the retention job.
+func setWebhookStatus(ctx context.Context, db outbox.Execer, log *zerolog.Logger, eventID, status string) error {
+	_, err := db.Exec(ctx, "UPDATE psp_webhooks SET status = $1, updated_at = NOW() WHERE event_id = $2", status, eventID)
+	if err != nil {
+		log.Error().Err(err).Str("event_id", eventID).Msg("Failed to update webhook status")
+		return err
+	}
+	return nil
+}
+
+// webhookEventID is the psp_webhooks event_id for event. Paystack numbers
+// charges and transfers separately, and sends several events for one
+// transfer, so only charges use the bare ID.
+func webhookEventID(event *types.PaystackWebhookEvent) string {
+	if event.Event == "charge.success" {
+		return fmt.Sprintf("%d", event.Data.ID)
+	}
+	return fmt.Sprintf("%s:%d", event.Event, event.Data.ID)
+}
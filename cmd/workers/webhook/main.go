@@ -5,12 +5,18 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Niiaks/Aegis/internal/config"
 	"github.com/Niiaks/Aegis/internal/database"
+	"github.com/Niiaks/Aegis/internal/events"
+	"github.com/Niiaks/Aegis/internal/health"
 	"github.com/Niiaks/Aegis/internal/kafka"
 	"github.com/Niiaks/Aegis/internal/logger"
+	"github.com/Niiaks/Aegis/internal/payout"
+	"github.com/Niiaks/Aegis/internal/psp"
 	"github.com/Niiaks/Aegis/internal/redis"
+	"github.com/Niiaks/Aegis/internal/transaction"
 )
 
 func main() {
@@ -37,20 +43,58 @@ func main() {
 	}
 	defer redis.Close()
 
+	paystackClient := psp.NewPaystackClient(cfg.Paystack.SecretKey, cfg.Paystack.BaseURL)
+	refunds := transaction.NewRefundVerifier(transaction.NewTransactionRepository(db.Pool, redis), paystackClient, &log, cfg.Refund)
+
 	// Initialize Webhook Worker
-	consumer, err := kafka.NewConsumer(kafka.DefaultConfig(cfg.Kafka.Brokers), kafka.GroupWebhookWorker, kafka.TopicWebhookPending)
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to initialize kafka consumer")
-	}
+	kafkaCfg := kafka.DefaultConfig(cfg.Kafka.Brokers)
+	kafkaCfg.ConcurrentPartitions = cfg.Kafka.ConcurrentPartitions
+	kafkaCfg.Logger = &log
+	nrApp := loggerService.GetApplication()
+	router := kafka.NewRouter()
+	router.Use(kafka.Logging(&log), kafka.Tracing(nrApp), kafka.Metrics(nrApp), kafka.Recover(), kafka.Timeout(30*time.Second))
+	router.HandleTopic(kafka.TopicWebhookPending, webhookHandler(db, redis, payout.NewSettler(db.Pool, redis, &log, events.SourceWebhookWorker), refunds, &log))
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Both consumers report to the health server; run starts the chosen one.
+	var consumer health.Consumer
+	var run func() error
+	if cfg.Kafka.TransactionalID != "" {
+		txConsumer, err := kafka.NewTransactionalConsumer(kafkaCfg, kafka.GroupWebhookWorker, cfg.Kafka.TransactionalID, router.Topics()...)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to initialize kafka transactional consumer")
+		}
+		defer txConsumer.Close()
+		consumer = txConsumer
+		run = func() error { return txConsumer.Run(ctx, kafka.WithoutOutput(router.Handler())) }
+	} else {
+		groupConsumer, err := kafka.NewConsumer(kafkaCfg, kafka.GroupWebhookWorker, router.Topics()...)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to initialize kafka consumer")
+		}
+		consumer = groupConsumer
+		run = func() error { return groupConsumer.Run(ctx, router.Handler()) }
+	}
+
 	go func() {
-		if err := consumer.Run(ctx, webhookHandler(db, redis, &log)); err != nil {
+		if err := run(); err != nil {
 			log.Error().Err(err).Msg("Relay service stopped with error")
 		}
 	}()
 
+	go func() {
+		if err := refunds.Start(ctx); err != nil {
+			log.Error().Err(err).Msg("Refund verifier stopped with error")
+		}
+	}()
+
+	healthServer := health.NewServer(cfg.Worker.HealthAddrOr(":8081"), consumer, cfg.Observability.HealthChecks, &log, nrApp)
+	healthServer.AddCheck("database", db.Pool.Ping)
+	healthServer.AddCheck("redis", redis.Ping)
+	healthServer.Start(ctx)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -58,5 +102,11 @@ func main() {
 	log.Info().Msg("Shutting down Webhook Worker...")
 	cancel()
 
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := healthServer.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("failed to shut down health server")
+	}
+
 	log.Info().Msg("Webhook Worker shutdown complete")
 }
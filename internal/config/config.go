@@ -25,6 +25,14 @@ type Config struct {
 	Observability *ObservabilityConfig
 	Paystack      PaystackConfig
 	Kafka         KafkaConfig
+	Outbox        OutboxConfig
+	Payout        PayoutConfig
+	Refund        RefundConfig
+	Retention     RetentionConfig
+	Idempotency   IdempotencyConfig
+	RateLimit     RateLimitConfig
+	Worker        WorkerConfig
+	Admin         AdminConfig
 }
 
 type PrimaryConfig struct {
@@ -50,6 +58,10 @@ type ServerConfig struct {
 	WriteTimeout       int
 	IdleTimeout        int
 	CORSAllowedOrigins []string
+	// TrustProxy takes the client IP from X-Forwarded-For or X-Real-IP, for
+	// logs and per-IP rate limits. Enable it only behind a proxy that sets
+	// those headers, since clients can forge them.
+	TrustProxy bool
 }
 
 type RedisConfig struct {
@@ -62,6 +74,7 @@ type RedisConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	LockTTL      time.Duration
+	LockWait     time.Duration
 	KeyPrefix    string
 }
 
@@ -101,7 +114,102 @@ type PaystackConfig struct {
 }
 
 type KafkaConfig struct {
-	Brokers []string
+	Brokers              []string
+	ConcurrentPartitions bool
+	// TransactionalID, when set, makes the webhook worker consume with a
+	// transactional session. It must be unique per worker instance.
+	TransactionalID string
+}
+
+type OutboxConfig struct {
+	// Mode is "listen" (wake on NOTIFY) or "poll" (query every PollInterval).
+	Mode             string
+	BatchSize        int
+	PollInterval     time.Duration
+	FallbackInterval time.Duration // safety-net poll while listening
+}
+
+type PayoutConfig struct {
+	// VerifyInterval is how often the payout worker checks pending transfers
+	// with Paystack, in case their webhook never arrived.
+	VerifyInterval time.Duration
+	// VerifyAfter is how long a transfer may stay pending before it is checked.
+	VerifyAfter time.Duration
+}
+
+type RefundConfig struct {
+	// VerifyInterval is how often the webhook worker checks pending refunds
+	// with Paystack, in case their webhook never arrived.
+	VerifyInterval time.Duration
+	// VerifyAfter is how long a refund may stay pending before it is checked.
+	// A refund Paystack has no record of by then is failed and its
+	// reservation released.
+	VerifyAfter time.Duration
+}
+
+type RetentionConfig struct {
+	// Mode is "archive" (move rows to the *_archive tables) or "delete".
+	Mode       string
+	OutboxAge  time.Duration
+	WebhookAge time.Duration
+	// ProcessedMessageAge must exceed the retention of every consumed topic,
+	// or a redelivered message could be applied twice.
+	ProcessedMessageAge time.Duration
+	BatchSize           int
+	Interval            time.Duration
+	DryRun              bool
+}
+
+type IdempotencyConfig struct {
+	// TTL is how long a key is remembered, in Redis and in idempotency_keys.
+	TTL time.Duration
+	// Methods are the HTTP methods the idempotency middleware enforces
+	// Idempotency-Key on; other requests pass through.
+	Methods        []string
+	PurgeInterval  time.Duration
+	PurgeBatchSize int
+}
+
+type RateLimitConfig struct {
+	Enabled bool
+	// Algorithm is "sliding" (sliding window) or "fixed" (fixed window,
+	// cheaper but bursty at window edges).
+	Algorithm string
+	// FailOpen lets requests through when Redis is unreachable; otherwise
+	// they are rejected with 503.
+	FailOpen bool
+	// IP limits each client IP. Requests carry no merchant identity, so
+	// clients are told apart by IP alone.
+	IP RateLimitPolicy
+	// Routes limits each client IP per route, keyed by "METHOD /route/pattern"
+	// as registered with chi.
+	Routes map[string]RateLimitPolicy
+}
+
+// RateLimitPolicy allows Limit requests per Window. A zero Limit disables it.
+type RateLimitPolicy struct {
+	Limit  int64
+	Window time.Duration
+}
+
+type AdminConfig struct {
+	// Token is the bearer token the /api/v1/admin routes require. Empty
+	// disables those routes.
+	Token string
+}
+
+type WorkerConfig struct {
+	// HealthAddr is where a Kafka worker serves its health endpoints. Empty
+	// uses the worker's own default port, so workers can share a host.
+	HealthAddr string
+}
+
+// HealthAddrOr returns HealthAddr, or fallback when it is not set.
+func (w WorkerConfig) HealthAddrOr(fallback string) string {
+	if w.HealthAddr != "" {
+		return w.HealthAddr
+	}
+	return fallback
 }
 
 // Helper functions for parsing env vars
@@ -146,6 +254,34 @@ func getEnvSlice(key string, fallback []string) []string {
 	return fallback
 }
 
+// parseRateLimitRoutes parses "METHOD /pattern=LIMIT/WINDOW" entries, e.g.
+// "POST /api/v1/transactions/payout=60/1m".
+func parseRateLimitRoutes(entries []string) (map[string]RateLimitPolicy, error) {
+	routes := make(map[string]RateLimitPolicy, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, policy, ok := strings.Cut(entry, "=")
+		limit, window, ok2 := strings.Cut(policy, "/")
+		if !ok || !ok2 || len(strings.Fields(route)) != 2 {
+			return nil, fmt.Errorf("AEGIS_RATELIMIT_ROUTES: invalid entry %q, want \"METHOD /pattern=LIMIT/WINDOW\"", entry)
+		}
+		n, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("AEGIS_RATELIMIT_ROUTES: invalid limit in %q", entry)
+		}
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("AEGIS_RATELIMIT_ROUTES: invalid window in %q", entry)
+		}
+		method, pattern := strings.Fields(route)[0], strings.Fields(route)[1]
+		routes[strings.ToUpper(method)+" "+pattern] = RateLimitPolicy{Limit: n, Window: d}
+	}
+	return routes, nil
+}
+
 func (c *ObservabilityConfig) GetLogLevel() string {
 	if c.Logging.Level == "" {
 		switch c.Environment {
@@ -187,6 +323,7 @@ func LoadConfig() (*Config, error) {
 			WriteTimeout:       getEnvInt("AEGIS_SERVER_WRITE_TIMEOUT", 30),
 			IdleTimeout:        getEnvInt("AEGIS_SERVER_IDLE_TIMEOUT", 60),
 			CORSAllowedOrigins: getEnvSlice("AEGIS_SERVER_CORS_ORIGINS", []string{"*"}),
+			TrustProxy:         getEnvBool("AEGIS_SERVER_TRUST_PROXY", false),
 		},
 		Redis: RedisConfig{
 			Address:      getEnv("AEGIS_REDIS_ADDRESS", "localhost:6379"),
@@ -198,6 +335,7 @@ func LoadConfig() (*Config, error) {
 			ReadTimeout:  getEnvDuration("AEGIS_REDIS_READ_TIMEOUT", 3*time.Second),
 			WriteTimeout: getEnvDuration("AEGIS_REDIS_WRITE_TIMEOUT", 3*time.Second),
 			LockTTL:      getEnvDuration("AEGIS_REDIS_LOCK_TTL", 30*time.Second),
+			LockWait:     getEnvDuration("AEGIS_REDIS_LOCK_WAIT", 5*time.Second),
 			KeyPrefix:    getEnv("AEGIS_REDIS_KEY_PREFIX", "aegis:"),
 		},
 		Observability: &ObservabilityConfig{
@@ -228,7 +366,53 @@ func LoadConfig() (*Config, error) {
 			BaseURL:       getEnv("AEGIS_PAYSTACK_BASE_URL", "https://api.paystack.co"),
 		},
 		Kafka: KafkaConfig{
-			Brokers: []string{"localhost:9092"},
+			Brokers:              []string{"localhost:9092"},
+			ConcurrentPartitions: getEnvBool("AEGIS_KAFKA_CONCURRENT_PARTITIONS", false),
+			TransactionalID:      getEnv("AEGIS_KAFKA_TRANSACTIONAL_ID", ""),
+		},
+		Outbox: OutboxConfig{
+			Mode:             getEnv("AEGIS_OUTBOX_MODE", "listen"),
+			BatchSize:        getEnvInt("AEGIS_OUTBOX_BATCH_SIZE", 500),
+			PollInterval:     getEnvDuration("AEGIS_OUTBOX_POLL_INTERVAL", 100*time.Millisecond),
+			FallbackInterval: getEnvDuration("AEGIS_OUTBOX_FALLBACK_INTERVAL", 5*time.Second),
+		},
+		Payout: PayoutConfig{
+			VerifyInterval: getEnvDuration("AEGIS_PAYOUT_VERIFY_INTERVAL", 5*time.Minute),
+			VerifyAfter:    getEnvDuration("AEGIS_PAYOUT_VERIFY_AFTER", 15*time.Minute),
+		},
+		Refund: RefundConfig{
+			VerifyInterval: getEnvDuration("AEGIS_REFUND_VERIFY_INTERVAL", 5*time.Minute),
+			VerifyAfter:    getEnvDuration("AEGIS_REFUND_VERIFY_AFTER", 15*time.Minute),
+		},
+		Retention: RetentionConfig{
+			Mode:                getEnv("AEGIS_RETENTION_MODE", "archive"),
+			OutboxAge:           getEnvDuration("AEGIS_RETENTION_OUTBOX_AGE", 7*24*time.Hour),
+			WebhookAge:          getEnvDuration("AEGIS_RETENTION_WEBHOOK_AGE", 90*24*time.Hour),
+			ProcessedMessageAge: getEnvDuration("AEGIS_RETENTION_PROCESSED_MESSAGE_AGE", 14*24*time.Hour),
+			BatchSize:           getEnvInt("AEGIS_RETENTION_BATCH_SIZE", 1000),
+			Interval:            getEnvDuration("AEGIS_RETENTION_INTERVAL", time.Hour),
+			DryRun:              getEnvBool("AEGIS_RETENTION_DRY_RUN", false),
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:            getEnvDuration("AEGIS_IDEMPOTENCY_TTL", 24*time.Hour),
+			Methods:        getEnvSlice("AEGIS_IDEMPOTENCY_METHODS", []string{"POST", "PUT", "PATCH"}),
+			PurgeInterval:  getEnvDuration("AEGIS_IDEMPOTENCY_PURGE_INTERVAL", time.Hour),
+			PurgeBatchSize: getEnvInt("AEGIS_IDEMPOTENCY_PURGE_BATCH_SIZE", 1000),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:   getEnvBool("AEGIS_RATELIMIT_ENABLED", true),
+			Algorithm: getEnv("AEGIS_RATELIMIT_ALGORITHM", "sliding"),
+			FailOpen:  getEnvBool("AEGIS_RATELIMIT_FAIL_OPEN", true),
+			IP: RateLimitPolicy{
+				Limit:  int64(getEnvInt("AEGIS_RATELIMIT_IP_LIMIT", 600)),
+				Window: getEnvDuration("AEGIS_RATELIMIT_IP_WINDOW", time.Minute),
+			},
+		},
+		Worker: WorkerConfig{
+			HealthAddr: getEnv("AEGIS_WORKER_HEALTH_ADDR", ""),
+		},
+		Admin: AdminConfig{
+			Token: getEnv("AEGIS_ADMIN_TOKEN", ""),
 		},
 	}
 
@@ -239,6 +423,57 @@ func LoadConfig() (*Config, error) {
 	if cfg.Database.Name == "" {
 		return nil, fmt.Errorf("AEGIS_DB_NAME is required")
 	}
+	if cfg.Outbox.Mode != "listen" && cfg.Outbox.Mode != "poll" {
+		return nil, fmt.Errorf("AEGIS_OUTBOX_MODE must be listen or poll")
+	}
+	if cfg.Outbox.BatchSize <= 0 {
+		return nil, fmt.Errorf("AEGIS_OUTBOX_BATCH_SIZE must be positive")
+	}
+	if cfg.Outbox.PollInterval <= 0 || cfg.Outbox.FallbackInterval <= 0 {
+		return nil, fmt.Errorf("AEGIS_OUTBOX_POLL_INTERVAL and AEGIS_OUTBOX_FALLBACK_INTERVAL must be positive")
+	}
+	if cfg.Retention.Mode != "archive" && cfg.Retention.Mode != "delete" {
+		return nil, fmt.Errorf("AEGIS_RETENTION_MODE must be archive or delete")
+	}
+	if cfg.Retention.BatchSize <= 0 {
+		return nil, fmt.Errorf("AEGIS_RETENTION_BATCH_SIZE must be positive")
+	}
+	if cfg.Retention.Interval <= 0 {
+		return nil, fmt.Errorf("AEGIS_RETENTION_INTERVAL must be positive")
+	}
+	if cfg.Idempotency.TTL <= 0 {
+		return nil, fmt.Errorf("AEGIS_IDEMPOTENCY_TTL must be positive")
+	}
+	if cfg.Idempotency.PurgeBatchSize <= 0 {
+		return nil, fmt.Errorf("AEGIS_IDEMPOTENCY_PURGE_BATCH_SIZE must be positive")
+	}
+	if cfg.Idempotency.PurgeInterval <= 0 {
+		return nil, fmt.Errorf("AEGIS_IDEMPOTENCY_PURGE_INTERVAL must be positive")
+	}
+	if cfg.RateLimit.Algorithm != "sliding" && cfg.RateLimit.Algorithm != "fixed" {
+		return nil, fmt.Errorf("AEGIS_RATELIMIT_ALGORITHM must be sliding or fixed")
+	}
+	if cfg.RateLimit.IP.Window <= 0 {
+		return nil, fmt.Errorf("AEGIS_RATELIMIT_IP_WINDOW must be positive")
+	}
+	routes, err := parseRateLimitRoutes(getEnvSlice("AEGIS_RATELIMIT_ROUTES", []string{
+		"POST /api/v1/transactions/payment-intent=300/1m",
+		"POST /api/v1/transactions/payout=60/1m",
+		"POST /api/v1/transactions/{id}/refunds=60/1m",
+	}))
+	if err != nil {
+		return nil, err
+	}
+	cfg.RateLimit.Routes = routes
+	if cfg.Payout.VerifyInterval <= 0 {
+		return nil, fmt.Errorf("AEGIS_PAYOUT_VERIFY_INTERVAL must be positive")
+	}
+	if cfg.Refund.VerifyInterval <= 0 {
+		return nil, fmt.Errorf("AEGIS_REFUND_VERIFY_INTERVAL must be positive")
+	}
+	if cfg.Redis.LockTTL <= 0 {
+		return nil, fmt.Errorf("AEGIS_REDIS_LOCK_TTL must be positive")
+	}
 
 	return cfg, nil
 }
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"maps"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    map[string]RateLimitPolicy
+		wantErr string
+	}{
+		{
+			name:    "default routes",
+			entries: []string{"POST /api/v1/transactions/payout=60/1m", "POST /api/v1/transactions/{id}/refunds=60/1m"},
+			want: map[string]RateLimitPolicy{
+				"POST /api/v1/transactions/payout":       {Limit: 60, Window: time.Minute},
+				"POST /api/v1/transactions/{id}/refunds": {Limit: 60, Window: time.Minute},
+			},
+		},
+		{
+			name:    "lowercase method, spaces and blanks",
+			entries: []string{" get  /api/v1/users=10/30s ", "", "  "},
+			want:    map[string]RateLimitPolicy{"GET /api/v1/users": {Limit: 10, Window: 30 * time.Second}},
+		},
+		{
+			name:    "zero limit disables",
+			entries: []string{"POST /x=0/1m"},
+			want:    map[string]RateLimitPolicy{"POST /x": {Limit: 0, Window: time.Minute}},
+		},
+		{
+			name:    "later entry wins",
+			entries: []string{"POST /x=1/1m", "post /x=2/1h"},
+			want:    map[string]RateLimitPolicy{"POST /x": {Limit: 2, Window: time.Hour}},
+		},
+		{name: "none", want: map[string]RateLimitPolicy{}},
+		{name: "no policy", entries: []string{"POST /x"}, wantErr: "invalid entry"},
+		{name: "no window", entries: []string{"POST /x=60"}, wantErr: "invalid entry"},
+		{name: "no method", entries: []string{"/x=60/1m"}, wantErr: "invalid entry"},
+		{name: "extra field", entries: []string{"POST /x y=60/1m"}, wantErr: "invalid entry"},
+		{name: "negative limit", entries: []string{"POST /x=-1/1m"}, wantErr: "invalid limit"},
+		{name: "non-numeric limit", entries: []string{"POST /x=ten/1m"}, wantErr: "invalid limit"},
+		{name: "bad window", entries: []string{"POST /x=60/minute"}, wantErr: "invalid window"},
+		{name: "zero window", entries: []string{"POST /x=60/0s"}, wantErr: "invalid window"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRateLimitRoutes(tt.entries)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseRateLimitRoutes() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRateLimitRoutes() error = %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("parseRateLimitRoutes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
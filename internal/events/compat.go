@@ -0,0 +1,149 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// CheckCompatibility checks every pair of consecutive schema versions that has
+// no upcaster between them: data written with the older schema must still be
+// valid under the newer one, since consumers will read it unchanged.
+func CheckCompatibility() error {
+	var errs []error
+	for eventType, versions := range registry {
+		for i := 1; i < len(versions); i++ {
+			older, newer := versions[i-1], versions[i]
+			if _, ok := upcasters[eventType][older.Version]; ok {
+				continue // the upcaster rewrites old data to the new shape
+			}
+			for _, issue := range Compare(older.Doc, newer.Doc) {
+				errs = append(errs, fmt.Errorf("%s v%d -> v%d: %s", eventType, older.Version, newer.Version, issue))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Compare lists the ways newer rejects data that older accepts. It understands
+// the keywords our schemas use (type, enum, const, format, required,
+// properties, additionalProperties, items and numeric and length bounds) and
+// treats anything else as unchanged.
+func Compare(older, newer map[string]any) []string {
+	var issues []string
+	compareSchema("$", older, newer, &issues)
+	sort.Strings(issues)
+	return issues
+}
+
+func compareSchema(at string, older, newer map[string]any, issues *[]string) {
+	report := func(format string, args ...any) {
+		*issues = append(*issues, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	oldTypes, newTypes := schemaTypes(older), schemaTypes(newer)
+	if newTypes != nil {
+		for _, t := range oldTypes {
+			if !slices.Contains(newTypes, t) && !(t == "integer" && slices.Contains(newTypes, "number")) {
+				report("type %s no longer allowed", t)
+			}
+		}
+		if oldTypes == nil {
+			report("type restricted to %v", newTypes)
+		}
+	}
+
+	if newEnum, ok := newer["enum"].([]any); ok {
+		oldEnum, ok := older["enum"].([]any)
+		if !ok {
+			report("enum added")
+		}
+		for _, v := range oldEnum {
+			if !slices.ContainsFunc(newEnum, func(n any) bool { return reflect.DeepEqual(n, v) }) {
+				report("enum value %v removed", v)
+			}
+		}
+	}
+	if c, ok := newer["const"]; ok && !reflect.DeepEqual(c, older["const"]) {
+		report("const changed to %v", c)
+	}
+	if f, ok := newer["format"]; ok && f != older["format"] {
+		report("format changed to %v", f)
+	}
+
+	for _, kw := range []string{"minimum", "exclusiveMinimum", "minLength", "minItems", "minProperties"} {
+		if n, ok := number(newer[kw]); ok {
+			if o, ok := number(older[kw]); !ok || n > o {
+				report("%s raised to %v", kw, newer[kw])
+			}
+		}
+	}
+	for _, kw := range []string{"maximum", "exclusiveMaximum", "maxLength", "maxItems", "maxProperties"} {
+		if n, ok := number(newer[kw]); ok {
+			if o, ok := number(older[kw]); !ok || n < o {
+				report("%s lowered to %v", kw, newer[kw])
+			}
+		}
+	}
+
+	oldRequired, _ := older["required"].([]any)
+	newRequired, _ := newer["required"].([]any)
+	for _, r := range newRequired {
+		if !slices.Contains(oldRequired, r) {
+			report("property %v became required", r)
+		}
+	}
+
+	oldProps, _ := older["properties"].(map[string]any)
+	newProps, _ := newer["properties"].(map[string]any)
+	closed := newer["additionalProperties"] == false
+	if closed && older["additionalProperties"] != false {
+		report("additional properties no longer allowed")
+	}
+	for name, o := range oldProps {
+		n, ok := newProps[name]
+		if !ok {
+			if closed {
+				report("property %s removed", name)
+			}
+			continue
+		}
+		oldProp, _ := o.(map[string]any)
+		newProp, _ := n.(map[string]any)
+		compareSchema(at+"."+name, oldProp, newProp, issues)
+	}
+	if n, ok := newer["items"].(map[string]any); ok {
+		o, _ := older["items"].(map[string]any)
+		compareSchema(at+"[]", o, n, issues)
+	}
+}
+
+// schemaTypes returns the types a schema allows, or nil if it does not say.
+func schemaTypes(s map[string]any) []string {
+	switch t := s["type"].(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case interface{ Float64() (float64, error) }:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
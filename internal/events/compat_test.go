@@ -0,0 +1,125 @@
+package events
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		older  string
+		newer  string
+		issues []string
+	}{
+		{
+			name:  "identical",
+			older: `{"type":"object","required":["a"],"properties":{"a":{"type":"string"}}}`,
+			newer: `{"type":"object","required":["a"],"properties":{"a":{"type":"string"}}}`,
+		},
+		{
+			name:  "optional property added",
+			older: `{"type":"object","properties":{"a":{"type":"string"}}}`,
+			newer: `{"type":"object","properties":{"a":{"type":"string"},"b":{"type":"integer"}}}`,
+		},
+		{
+			name:  "required dropped",
+			older: `{"type":"object","required":["a"]}`,
+			newer: `{"type":"object"}`,
+		},
+		{
+			name:   "property became required",
+			older:  `{"type":"object","required":["a"]}`,
+			newer:  `{"type":"object","required":["a","b"]}`,
+			issues: []string{"$: property b became required"},
+		},
+		{
+			name:   "nested type narrowed",
+			older:  `{"properties":{"a":{"type":["string","null"]}}}`,
+			newer:  `{"properties":{"a":{"type":"string"}}}`,
+			issues: []string{"$.a: type null no longer allowed"},
+		},
+		{
+			name:  "integer widened to number",
+			older: `{"properties":{"a":{"type":"integer"}}}`,
+			newer: `{"properties":{"a":{"type":"number"}}}`,
+		},
+		{
+			name:   "type added where none was",
+			older:  `{"properties":{"a":{}}}`,
+			newer:  `{"properties":{"a":{"type":"string"}}}`,
+			issues: []string{"$.a: type restricted to [string]"},
+		},
+		{
+			name:   "enum value removed",
+			older:  `{"enum":["a","b"]}`,
+			newer:  `{"enum":["a"]}`,
+			issues: []string{"$: enum value b removed"},
+		},
+		{
+			name:   "enum added",
+			older:  `{"type":"string"}`,
+			newer:  `{"type":"string","enum":["a"]}`,
+			issues: []string{"$: enum added"},
+		},
+		{
+			name:   "const and format changed",
+			older:  `{"const":"a","format":"uuid"}`,
+			newer:  `{"const":"b","format":"email"}`,
+			issues: []string{"$: const changed to b", "$: format changed to email"},
+		},
+		{
+			name:   "bounds tightened",
+			older:  `{"minimum":0,"maxLength":10}`,
+			newer:  `{"minimum":1,"maxLength":5}`,
+			issues: []string{"$: maxLength lowered to 5", "$: minimum raised to 1"},
+		},
+		{
+			name:  "bounds loosened",
+			older: `{"minimum":1,"maxLength":5}`,
+			newer: `{"minimum":0,"maxLength":10}`,
+		},
+		{
+			name:   "closed with property removed",
+			older:  `{"properties":{"a":{},"b":{}}}`,
+			newer:  `{"properties":{"a":{}},"additionalProperties":false}`,
+			issues: []string{"$: additional properties no longer allowed", "$: property b removed"},
+		},
+		{
+			name:  "open with property removed",
+			older: `{"properties":{"a":{},"b":{}}}`,
+			newer: `{"properties":{"a":{}}}`,
+		},
+		{
+			name:   "array items narrowed",
+			older:  `{"items":{"properties":{"a":{"type":"string"}}}}`,
+			newer:  `{"items":{"properties":{"a":{"type":"string","minLength":1}}}}`,
+			issues: []string{"$[].a: minLength raised to 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compare(schema(t, tt.older), schema(t, tt.newer))
+			if !slices.Equal(got, tt.issues) {
+				t.Errorf("Compare() = %q, want %q", got, tt.issues)
+			}
+		})
+	}
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	if err := CheckCompatibility(); err != nil {
+		t.Fatalf("embedded schemas are incompatible: %v", err)
+	}
+}
+
+func schema(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatalf("bad schema %s: %v", s, err)
+	}
+	return m
+}
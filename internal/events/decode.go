@@ -0,0 +1,120 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Niiaks/Aegis/internal/kafka"
+)
+
+var (
+	// ErrUnsupportedVersion means the event is newer than this consumer
+	// understands. It is returned as an ordinary error so the message is
+	// retried and dead-lettered, and can be replayed once consumers catch up.
+	ErrUnsupportedVersion = errors.New("unsupported event schema version")
+	// ErrObsolete means the payload predates a shape that can still be
+	// upcast. Consumers acknowledge and drop such messages.
+	ErrObsolete = errors.New("obsolete event payload")
+)
+
+// Upcaster rewrites an event's data from one schema version to the next.
+type Upcaster func(data json.RawMessage) (json.RawMessage, error)
+
+// upcasters[type][n] turns version n data into version n+1. A step without an
+// upcaster leaves the data unchanged, so the two schemas must be compatible;
+// CheckCompatibility enforces that. Version 0 is the bare payload published
+// before envelopes existed, which version 1 wraps unchanged.
+//
+// To change an event's data shape, add the next schema version and, if it is
+// not backward compatible, an upcaster from the previous version.
+var upcasters = map[string]map[int]Upcaster{
+	kafka.EventLedgerEntryCreated: {0: ledgerEntryCreatedV0},
+}
+
+// topicEvents maps each topic to the one event type published on it. Messages
+// from before the event_type header existed are typed by their topic.
+var topicEvents = map[string]string{
+	kafka.TopicPaymentCreated:      kafka.EventPaymentIntentCreated,
+	kafka.TopicWebhookPending:      kafka.EventWebhookReceived,
+	kafka.TopicBalanceUpdate:       kafka.EventLedgerEntryCreated,
+	kafka.TopicPayoutPending:       kafka.EventPayoutRequested,
+	kafka.TopicPayoutStatusUpdate:  kafka.EventPayoutStatusUpdated,
+	kafka.TopicDiscrepancyDetected: kafka.EventDiscrepancyDetected,
+	kafka.TopicReconciliationJob:   kafka.EventReconciliationRequested,
+}
+
+// Decode unwraps msg's envelope, upcasts its data to the current schema
+// version, validates it and unmarshals it into v. A message without an
+// envelope is treated as version 0 of the type in its event_type header, or
+// of the type published on its topic if it has no header.
+func Decode(msg *kafka.Message, v any) (*Envelope, error) {
+	// Probe first: a bare payload's own fields may not fit the envelope's types.
+	var probe struct {
+		SpecVersion string `json:"specversion"`
+	}
+	if err := json.Unmarshal(msg.Value, &probe); err != nil {
+		return nil, err
+	}
+
+	env := Envelope{
+		Type:          msg.Headers[kafka.HeaderEventType],
+		CorrelationID: msg.Headers["X-Request-ID"],
+		Data:          msg.Value,
+	}
+	if probe.SpecVersion != "" {
+		if err := json.Unmarshal(msg.Value, &env); err != nil {
+			return nil, err
+		}
+	} else if env.Type == "" {
+		env.Type = topicEvents[msg.Topic]
+	}
+
+	data, err := upcast(env.Type, env.SchemaVersion, env.Data)
+	if err != nil {
+		return &env, err
+	}
+	env.Data, env.SchemaVersion = data, CurrentVersion(env.Type)
+
+	if err := Validate(env.Type, env.SchemaVersion, data); err != nil {
+		return &env, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return &env, err
+	}
+	return &env, nil
+}
+
+func upcast(eventType string, version int, data json.RawMessage) (json.RawMessage, error) {
+	current := CurrentVersion(eventType)
+	if version > current {
+		return nil, fmt.Errorf("%w: %s v%d, latest known is v%d", ErrUnsupportedVersion, eventType, version, current)
+	}
+	for ; version < current; version++ {
+		up, ok := upcasters[eventType][version]
+		if !ok {
+			continue
+		}
+		var err error
+		if data, err = up(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// ledgerEntryCreatedV0 rejects the full Paystack webhook bodies that early
+// builds published as ledger entry events.
+func ledgerEntryCreatedV0(data json.RawMessage) (json.RawMessage, error) {
+	var probe struct {
+		UserID    string `json:"user_id"`
+		NetAmount int64  `json:"net_amount"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	if probe.UserID == "" || probe.NetAmount <= 0 {
+		return nil, fmt.Errorf("%w: ledger entry without user_id or net_amount", ErrObsolete)
+	}
+	return data, nil
+}
@@ -0,0 +1,119 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/Niiaks/Aegis/internal/kafka"
+	"github.com/Niiaks/Aegis/pkg/types"
+)
+
+const (
+	testUserID        = "6f1c1f5e-5f49-4b8a-9c1a-1f6b7a0e9d11"
+	testTransactionID = "0b7e5c2a-3d4f-4e1a-8b9c-2d3e4f5a6b7c"
+)
+
+func TestDecode(t *testing.T) {
+	balance := `{"transaction_id":"` + testTransactionID + `","user_id":"` + testUserID + `","net_amount":700,"currency":"GHS"}`
+	webhook := `{"event":"charge.success","data":{"id":1,"reference":"ref","amount":1000,"currency":"GHS"}}`
+	envelope := func(version int, data string) string {
+		return `{"specversion":"1.0","id":"e1","source":"aegis/webhook-worker","type":"aegis.ledger.entry.created",` +
+			`"time":"2026-10-18T00:00:00Z","datacontenttype":"application/json","schemaversion":` +
+			strconv.Itoa(version) + `,"correlationid":"req-1","data":` + data + `}`
+	}
+
+	tests := []struct {
+		name    string
+		msg     kafka.Message
+		want    types.BalanceUpdateEvent
+		version int
+		err     error
+	}{
+		{
+			name: "enveloped current version",
+			msg: kafka.Message{
+				Topic: kafka.TopicBalanceUpdate,
+				Value: []byte(envelope(1, balance)),
+			},
+			want:    types.BalanceUpdateEvent{TransactionID: testTransactionID, UserID: testUserID, NetAmount: 700, Currency: "GHS"},
+			version: 1,
+		},
+		{
+			name: "bare payload with event_type header",
+			msg: kafka.Message{
+				Topic:   kafka.TopicBalanceUpdate,
+				Value:   []byte(balance),
+				Headers: map[string]string{kafka.HeaderEventType: kafka.EventLedgerEntryCreated},
+			},
+			want:    types.BalanceUpdateEvent{TransactionID: testTransactionID, UserID: testUserID, NetAmount: 700, Currency: "GHS"},
+			version: 1,
+		},
+		{
+			name: "header-less legacy payload typed by topic",
+			msg: kafka.Message{
+				Topic: kafka.TopicBalanceUpdate,
+				Value: []byte(balance),
+			},
+			want:    types.BalanceUpdateEvent{TransactionID: testTransactionID, UserID: testUserID, NetAmount: 700, Currency: "GHS"},
+			version: 1,
+		},
+		{
+			name: "header-less legacy webhook body is obsolete",
+			msg: kafka.Message{
+				Topic: kafka.TopicBalanceUpdate,
+				Value: []byte(webhook),
+			},
+			err: ErrObsolete,
+		},
+		{
+			name: "enveloped version 0 webhook body is obsolete",
+			msg: kafka.Message{
+				Topic: kafka.TopicBalanceUpdate,
+				Value: []byte(envelope(0, webhook)),
+			},
+			err: ErrObsolete,
+		},
+		{
+			name: "newer version than known",
+			msg: kafka.Message{
+				Topic: kafka.TopicBalanceUpdate,
+				Value: []byte(envelope(9, balance)),
+			},
+			err: ErrUnsupportedVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got types.BalanceUpdateEvent
+			env, err := Decode(&tt.msg, &got)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Decode() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Decode() data = %+v, want %+v", got, tt.want)
+			}
+			if env.Type != kafka.EventLedgerEntryCreated {
+				t.Errorf("Decode() type = %q, want %q", env.Type, kafka.EventLedgerEntryCreated)
+			}
+			if env.SchemaVersion != tt.version {
+				t.Errorf("Decode() version = %d, want %d", env.SchemaVersion, tt.version)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalidJSON(t *testing.T) {
+	var v json.RawMessage
+	if _, err := Decode(&kafka.Message{Topic: kafka.TopicBalanceUpdate, Value: []byte("{")}, &v); err == nil {
+		t.Fatal("Decode() of truncated JSON succeeded")
+	}
+}
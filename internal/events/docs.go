@@ -0,0 +1,77 @@
+package events
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// WriteDocs renders the schema registry as Markdown for teams consuming our
+// topics.
+func WriteDocs(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Event catalog\n\n")
+	b.WriteString("<!-- Generated by `aegis schemas docs`. Do not edit. -->\n\n")
+	b.WriteString("Every event is a CloudEvents 1.0 envelope in structured JSON mode (see ADR-008). ")
+	b.WriteString("`type` names the event, `schemaversion` the version of `data` described below, ")
+	b.WriteString("and `correlationid` the request that caused it. Messages also carry the event type in the `event_type` header.\n")
+
+	var current string
+	for _, s := range Schemas() {
+		if s.EventType != current {
+			current = s.EventType
+			fmt.Fprintf(&b, "\n## `%s`\n\nCurrent version: v%d\n", current, CurrentVersion(current))
+		}
+
+		fmt.Fprintf(&b, "\n### v%d", s.Version)
+		if title, ok := s.Doc["title"].(string); ok {
+			fmt.Fprintf(&b, ": %s", title)
+		}
+		b.WriteString("\n\n")
+		if desc, ok := s.Doc["description"].(string); ok {
+			b.WriteString(desc + "\n\n")
+		}
+		b.WriteString("| Field | Type | Required | Description |\n| ----- | ---- | -------- | ----------- |\n")
+		writeFields(&b, "", s.Doc)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFields(b *strings.Builder, prefix string, schema map[string]any) {
+	props, _ := schema["properties"].(map[string]any)
+	required, _ := schema["required"].([]any)
+
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		prop, _ := props[name].(map[string]any)
+		field := prefix + name
+
+		typ := strings.Join(schemaTypes(prop), " \\| ")
+		if enum, ok := prop["enum"].([]any); ok {
+			values := make([]string, len(enum))
+			for i, v := range enum {
+				values[i] = fmt.Sprintf("`%v`", v)
+			}
+			typ = "one of " + strings.Join(values, ", ")
+		}
+		if format, ok := prop["format"].(string); ok {
+			typ += " (" + format + ")"
+		}
+		req := ""
+		if slices.Contains(required, any(name)) {
+			req = "yes"
+		}
+		desc, _ := prop["description"].(string)
+
+		fmt.Fprintf(b, "| `%s` | %s | %s | %s |\n", field, typ, req, desc)
+		writeFields(b, field+".", prop)
+	}
+}
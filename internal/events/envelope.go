@@ -0,0 +1,62 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SpecVersion is the CloudEvents version envelopes conform to.
+const SpecVersion = "1.0"
+
+// Sources name the component that produced an event, as the CloudEvents
+// "source" attribute.
+const (
+	SourceTransactions   = "aegis/transactions"
+	SourceWebhooks       = "aegis/webhooks"
+	SourceReconciliation = "aegis/reconciliation"
+	SourceWebhookWorker  = "aegis/webhook-worker"
+	SourcePayoutWorker   = "aegis/payout-worker"
+)
+
+// Envelope wraps every event written to the outbox. It is a CloudEvents 1.0
+// event in structured JSON mode, with the schema version and correlation ID
+// carried as extension attributes.
+type Envelope struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	// SchemaVersion is the version of Data's shape for Type. Consumers upcast
+	// older versions before decoding; see Decode.
+	SchemaVersion int             `json:"schemaversion"`
+	CorrelationID string          `json:"correlationid"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// New validates data against the current schema for eventType and wraps it in
+// an envelope at that version.
+func New(eventType, source, correlationID string, data any) (*Envelope, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	version := CurrentVersion(eventType)
+	if err := Validate(eventType, version, raw); err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		SpecVersion:     SpecVersion,
+		ID:              uuid.New().String(),
+		Source:          source,
+		Type:            eventType,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		SchemaVersion:   version,
+		CorrelationID:   correlationID,
+		Data:            raw,
+	}, nil
+}
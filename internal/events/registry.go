@@ -0,0 +1,134 @@
+package events
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// ErrInvalidEvent means an event's data does not match its JSON schema.
+var ErrInvalidEvent = errors.New("event does not match its schema")
+
+// Schemas live in schemas/<event type>/v<version>.json and describe an event's
+// data, not the envelope. They are embedded so producers and consumers always
+// agree on them.
+//
+//go:embed schemas
+var schemaFS embed.FS
+
+// Schema is one version of an event type's data contract.
+type Schema struct {
+	EventType string
+	Version   int
+	// Doc is the schema document, for docs generation and compatibility checks.
+	Doc      map[string]any
+	compiled *jsonschema.Schema
+}
+
+// registry maps event type to its schemas, ordered by version.
+var registry = loadSchemas()
+
+func loadSchemas() map[string][]*Schema {
+	c := jsonschema.NewCompiler()
+	c.AssertFormat()
+
+	var all []*Schema
+	err := fs.WalkDir(schemaFS, "schemas", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path.Base(p), "v"), ".json"))
+		if err != nil {
+			return fmt.Errorf("%s: file name must be v<version>.json", p)
+		}
+		raw, err := schemaFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		url := "embed:///" + p
+		if err := c.AddResource(url, doc); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		compiled, err := c.Compile(url)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		obj, _ := doc.(map[string]any)
+		all = append(all, &Schema{EventType: path.Base(path.Dir(p)), Version: version, Doc: obj, compiled: compiled})
+		return nil
+	})
+	if err != nil {
+		panic("events: invalid embedded schema: " + err.Error())
+	}
+
+	reg := make(map[string][]*Schema)
+	for _, s := range all {
+		reg[s.EventType] = append(reg[s.EventType], s)
+	}
+	for eventType, versions := range reg {
+		slices.SortFunc(versions, func(a, b *Schema) int { return a.Version - b.Version })
+		for i, s := range versions {
+			if s.Version != i+1 {
+				panic(fmt.Sprintf("events: %s schemas must be numbered from v1 without gaps", eventType))
+			}
+		}
+	}
+	return reg
+}
+
+// CurrentVersion is the schema version new events of eventType are written
+// with: its highest registered schema, or 1 for types without one.
+func CurrentVersion(eventType string) int {
+	if versions := registry[eventType]; len(versions) > 0 {
+		return versions[len(versions)-1].Version
+	}
+	return 1
+}
+
+// Schemas lists every registered schema, ordered by event type and version.
+func Schemas() []*Schema {
+	types := make([]string, 0, len(registry))
+	for eventType := range registry {
+		types = append(types, eventType)
+	}
+	slices.Sort(types)
+
+	var out []*Schema
+	for _, eventType := range types {
+		out = append(out, registry[eventType]...)
+	}
+	return out
+}
+
+// Validate checks data against the schema for eventType at version. Types
+// without schemas are not validated.
+func Validate(eventType string, version int, data []byte) error {
+	versions, ok := registry[eventType]
+	if !ok {
+		return nil
+	}
+	if version < 1 || version > len(versions) {
+		return fmt.Errorf("%w: no schema for %s v%d", ErrUnsupportedVersion, eventType, version)
+	}
+
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %s v%d: %v", ErrInvalidEvent, eventType, version, err)
+	}
+	if err := versions[version-1].compiled.Validate(inst); err != nil {
+		return fmt.Errorf("%w: %s v%d: %v", ErrInvalidEvent, eventType, version, err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/config"
+	"github.com/Niiaks/Aegis/internal/kafka"
+	"github.com/go-chi/chi/v5"
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/rs/zerolog"
+)
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+// Consumer is implemented by kafka.Consumer and kafka.TransactionalConsumer.
+type Consumer interface {
+	Running() bool
+	Ping(ctx context.Context) error
+	Stats(ctx context.Context) kafka.ConsumerStats
+}
+
+// Server exposes a worker's health over HTTP:
+//
+//	GET /health/live      200 while the consumer loop is running
+//	GET /health/ready     200 when Kafka and every dependency check respond
+//	GET /health/consumer  per-partition lag, last processed offset, error rate and DLQ count
+type Server struct {
+	consumer Consumer
+	cfg      config.HealthChecksConfig
+	logger   *zerolog.Logger
+	nrApp    *newrelic.Application
+	srv      *http.Server
+
+	mu     sync.Mutex
+	checks map[string]Check
+}
+
+// NewServer builds a health server listening on addr. Dependency checks are
+// registered with AddCheck; only those named in cfg.Checks are run. nrApp may
+// be nil, in which case lag is not reported to New Relic.
+func NewServer(addr string, consumer Consumer, cfg config.HealthChecksConfig, logger *zerolog.Logger, nrApp *newrelic.Application) *Server {
+	s := &Server{
+		consumer: consumer,
+		cfg:      cfg,
+		logger:   logger,
+		nrApp:    nrApp,
+		checks:   make(map[string]Check),
+	}
+
+	r := chi.NewRouter()
+	r.Get("/health/live", s.live)
+	r.Get("/health/ready", s.ready)
+	r.Get("/health/consumer", s.consumerStats)
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           r,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+func (s *Server) AddCheck(name string, check Check) {
+	if !slices.Contains(s.cfg.Checks, name) {
+		return
+	}
+	s.mu.Lock()
+	s.checks[name] = check
+	s.mu.Unlock()
+}
+
+// Start serves in the background and, with New Relic enabled, records
+// consumer lag and counters every cfg.Interval until ctx is cancelled.
+func (s *Server) Start(ctx context.Context) {
+	if !s.cfg.Enabled {
+		return
+	}
+	go func() {
+		s.logger.Info().Str("addr", s.srv.Addr).Msg("Starting health server")
+		if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error().Err(err).Msg("Health server stopped with error")
+		}
+	}()
+	if s.nrApp != nil {
+		go s.reportMetrics(ctx)
+	}
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	if !s.cfg.Enabled {
+		return nil
+	}
+	return s.srv.Shutdown(ctx)
+}
+
+func (s *Server) reportMetrics(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		statsCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+		stats := s.consumer.Stats(statsCtx)
+		cancel()
+
+		prefix := "Kafka/Consumer/" + stats.Group + "/"
+		s.nrApp.RecordCustomMetric(prefix+"lag", float64(stats.TotalLag))
+		s.nrApp.RecordCustomMetric(prefix+"error_rate", stats.ErrorRate)
+		s.nrApp.RecordCustomMetric(prefix+"dlq", float64(stats.DLQ))
+		for _, p := range stats.Partitions {
+			if p.Lag >= 0 {
+				s.nrApp.RecordCustomMetric(prefix+p.Topic+"/"+strconv.Itoa(int(p.Partition))+"/lag", float64(p.Lag))
+			}
+		}
+	}
+}
+
+func (s *Server) live(w http.ResponseWriter, r *http.Request) {
+	if !s.consumer.Running() {
+		writeStatus(w, http.StatusServiceUnavailable, map[string]any{"status": "down"})
+		return
+	}
+	writeStatus(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
+func (s *Server) ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.Timeout)
+	defer cancel()
+
+	s.mu.Lock()
+	checks := make(map[string]Check, len(s.checks)+1)
+	for name, check := range s.checks {
+		checks[name] = check
+	}
+	s.mu.Unlock()
+	checks["kafka"] = s.consumer.Ping
+
+	results := make(map[string]string, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	healthy := s.consumer.Running()
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := "ok"
+			if err := check(ctx); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			results[name] = result
+			healthy = healthy && result == "ok"
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	status, code := "ok", http.StatusOK
+	if !healthy {
+		status, code = "unavailable", http.StatusServiceUnavailable
+		s.logger.Warn().Interface("checks", results).Bool("running", s.consumer.Running()).Msg("Worker not ready")
+	}
+	writeStatus(w, code, map[string]any{"status": status, "checks": results})
+}
+
+func (s *Server) consumerStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.Timeout)
+	defer cancel()
+	writeStatus(w, http.StatusOK, s.consumer.Stats(ctx))
+}
+
+func writeStatus(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,85 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/rs/zerolog"
+)
+
+const purgeQuery = `
+	DELETE FROM idempotency_keys
+	WHERE id IN (
+		SELECT id FROM idempotency_keys
+		WHERE expires_at < $1
+		ORDER BY expires_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)`
+
+// PurgeJob deletes expired idempotency keys.
+type PurgeJob struct {
+	db     *pgxpool.Pool
+	logger *zerolog.Logger
+	nrApp  *newrelic.Application
+	cfg    config.IdempotencyConfig
+}
+
+// NewPurgeJob builds a purge job. nrApp may be nil, in which case counts are
+// only logged.
+func NewPurgeJob(db *pgxpool.Pool, logger *zerolog.Logger, nrApp *newrelic.Application, cfg config.IdempotencyConfig) *PurgeJob {
+	return &PurgeJob{
+		db:     db,
+		logger: logger,
+		nrApp:  nrApp,
+		cfg:    cfg,
+	}
+}
+
+// Start purges immediately and then every configured interval until ctx is
+// cancelled.
+func (j *PurgeJob) Start(ctx context.Context) error {
+	j.logger.Info().Msg("Starting Idempotency Purge Job")
+	ticker := time.NewTicker(j.cfg.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Run(ctx); err != nil && ctx.Err() == nil {
+			j.logger.Error().Err(err).Msg("Idempotency purge failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			j.logger.Info().Msg("Stopping Idempotency Purge Job")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run deletes every key expired by now, in batches of PurgeBatchSize rows so
+// locks are never held for long.
+func (j *PurgeJob) Run(ctx context.Context) (int64, error) {
+	cutoff := time.Now()
+
+	var total int64
+	for ctx.Err() == nil {
+		tag, err := j.db.Exec(ctx, purgeQuery, cutoff, j.cfg.PurgeBatchSize)
+		if err != nil {
+			return total, err
+		}
+		total += tag.RowsAffected()
+		if tag.RowsAffected() < int64(j.cfg.PurgeBatchSize) {
+			break
+		}
+	}
+
+	j.logger.Info().Str("table", "idempotency_keys").Int64("rows", total).Msg("Idempotency purge complete")
+	if j.nrApp != nil {
+		j.nrApp.RecordCustomMetric("Idempotency/purged", float64(total))
+	}
+	return total, ctx.Err()
+}
@@ -0,0 +1,325 @@
+package idempotency
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/model"
+	"github.com/Niiaks/Aegis/internal/redis"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// Header is the request header carrying the client's idempotency key.
+const Header = "Idempotency-Key"
+
+var (
+	ErrMissingKey = errors.New("Idempotency-Key header is required")
+	ErrInProgress = errors.New("request in progress: please retry later")
+	// ErrFingerprintMismatch means the key was first used for a request with a
+	// different method, path or body.
+	ErrFingerprintMismatch = errors.New("idempotency key reused with a different request")
+	// ErrKeyUsed means the key was committed by a request that never recorded
+	// its response, e.g. because a PSP call failed after the business write.
+	ErrKeyUsed = errors.New("idempotency key already used by an unfinished request")
+)
+
+// Execer is satisfied by pgx.Tx and *pgxpool.Pool. Pass the transaction that
+// makes the business change so the key commits with it.
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// Store keeps idempotency keys in Redis for fast lookups and in the
+// idempotency_keys table as the durable record, so a Redis flush cannot make
+// a completed request run twice.
+type Store struct {
+	db    *pgxpool.Pool
+	redis *redis.Client
+	log   *zerolog.Logger
+	ttl   time.Duration
+}
+
+func NewStore(db *pgxpool.Pool, redis *redis.Client, log *zerolog.Logger, ttl time.Duration) *Store {
+	return &Store{
+		db:    db,
+		redis: redis,
+		log:   log,
+		ttl:   ttl,
+	}
+}
+
+// Fingerprint identifies a request for request_hash: the hex SHA-256 of its
+// method, path and raw body. Fields are length-prefixed so no two requests
+// encode the same.
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(method), []byte(path), body} {
+		fmt.Fprintf(h, "%d:", len(part))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FromRequest builds the key for r from its Idempotency-Key header and
+// fingerprint. Keys are scoped to their path only: requests carry no merchant
+// identity, so clients must pick keys that are unique across merchants (e.g.
+// UUIDs). It reads r's body and replaces it so the handler can still decode it.
+func FromRequest(r *http.Request) (*model.IdempotencyKey, error) {
+	key := r.Header.Get(Header)
+	if key == "" {
+		return nil, ErrMissingKey
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return &model.IdempotencyKey{
+		Key:         key,
+		RequestHash: Fingerprint(r.Method, r.URL.Path, body),
+		RequestPath: r.URL.Path,
+	}, nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying k, for the handler behind the
+// idempotency middleware.
+func NewContext(ctx context.Context, k *model.IdempotencyKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, k)
+}
+
+// FromContext returns the key claimed for the request, or nil if the route is
+// not behind the idempotency middleware.
+func FromContext(ctx context.Context) *model.IdempotencyKey {
+	k, _ := ctx.Value(contextKey{}).(*model.IdempotencyKey)
+	return k
+}
+
+// Response is a completed request's response, replayed to matching retries.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Write replays the response, marked with an Idempotent-Replayed header.
+func (resp *Response) Write(w http.ResponseWriter) {
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
+
+// replayHeader copies the response headers worth replaying; per-request ones
+// such as the request ID are left out.
+func replayHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range []string{"X-Request-Id", "Date", "Set-Cookie", "Content-Length"} {
+		out.Del(name)
+	}
+	return out
+}
+
+// orJSONHeader fills in the headers of responses stored before headers were
+// recorded, all of which were JSON.
+func orJSONHeader(h http.Header) http.Header {
+	if h == nil {
+		return http.Header{"Content-Type": {"application/json"}}
+	}
+	return h
+}
+
+// redisKey scopes the key to its path, as idempotency_keys does, so routes
+// cannot collide.
+func redisKey(k *model.IdempotencyKey) string {
+	return k.RequestPath + ":" + k.Key
+}
+
+// Begin claims k.Key and sets k.ExpiresAt. It returns the stored response
+// when the key has already completed for the same fingerprint, from Redis or,
+// when Redis has no record of it, from Postgres. It fails with
+// ErrFingerprintMismatch if the key was used for a different request. A nil
+// response and error means the caller owns the key and must Insert it with its
+// business write, then call Complete or Fail.
+//
+// If Redis is unreachable Begin relies on Postgres alone: Insert still rejects
+// a second use of the key when the business transaction commits.
+func (s *Store) Begin(ctx context.Context, k *model.IdempotencyKey) (*Response, error) {
+	k.ExpiresAt = time.Now().Add(s.ttl)
+
+	cached, err := s.redis.CheckAndSetIdempotency(ctx, redisKey(k), k.RequestHash, s.ttl)
+	if cached != nil && cached.RequestHash != "" && cached.RequestHash != k.RequestHash {
+		return nil, ErrFingerprintMismatch
+	}
+	switch {
+	case errors.Is(err, redis.ErrKeyExists):
+		return nil, ErrInProgress
+	case cached != nil:
+		return &Response{
+			StatusCode: cmp.Or(cached.StatusCode, http.StatusOK),
+			Header:     orJSONHeader(cached.Headers),
+			Body:       cached.Response,
+		}, nil
+	case err != nil:
+		s.log.Warn().Err(err).Str("idempotency_key", k.Key).Msg("Redis idempotency check failed, falling back to Postgres")
+	}
+
+	stored, err := s.lookup(ctx, k)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		s.release(ctx, k)
+		return nil, fmt.Errorf("failed to look up idempotency key: %w", err)
+	}
+
+	// Drop our Redis claim in each case below, so the key is neither reported
+	// as in progress nor left holding this request's fingerprint.
+	if stored.RequestHash != k.RequestHash {
+		s.release(ctx, k)
+		return nil, ErrFingerprintMismatch
+	}
+	if stored.ResponseBody == nil {
+		// The business write committed but its response was never recorded.
+		s.release(ctx, k)
+		return nil, ErrKeyUsed
+	}
+
+	resp := &Response{
+		StatusCode: cmp.Or(stored.ResponseCode, http.StatusOK),
+		Header:     orJSONHeader(stored.ResponseHeaders),
+		Body:       stored.ResponseBody,
+	}
+
+	// Redis lost the key: restore it for the rest of its lifetime.
+	if ttl := time.Until(stored.ExpiresAt); ttl > 0 {
+		if err := s.redis.MarkIdempotencyComplete(ctx, redisKey(k), s.result(k, resp), ttl); err != nil {
+			s.log.Warn().Err(err).Str("idempotency_key", k.Key).Msg("Failed to restore idempotency key in Redis")
+		}
+	}
+	return resp, nil
+}
+
+func (s *Store) result(k *model.IdempotencyKey, resp *Response) *redis.IdempotencyResult {
+	return &redis.IdempotencyResult{
+		RequestHash: k.RequestHash,
+		StatusCode:  resp.StatusCode,
+		Headers:     resp.Header,
+		Response:    resp.Body,
+	}
+}
+
+func (s *Store) lookup(ctx context.Context, k *model.IdempotencyKey) (*model.IdempotencyKey, error) {
+	var stored model.IdempotencyKey
+	var code *int
+	err := s.db.QueryRow(ctx, `
+		SELECT id, key, request_hash, request_path, response_code, response_body, response_headers, user_id, expires_at, created_at
+		FROM idempotency_keys
+		WHERE key = $1 AND request_path = $2 AND expires_at > NOW()`,
+		k.Key, k.RequestPath,
+	).Scan(&stored.ID, &stored.Key, &stored.RequestHash, &stored.RequestPath, &code, &stored.ResponseBody, &stored.ResponseHeaders, &stored.UserID, &stored.ExpiresAt, &stored.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if code != nil {
+		stored.ResponseCode = *code
+	}
+	return &stored, nil
+}
+
+// Insert records k in the caller's transaction. An expired row that has not
+// been purged yet is reused; a live one fails with ErrKeyUsed, rolling the
+// business write back.
+func Insert(ctx context.Context, db Execer, k *model.IdempotencyKey) error {
+	tag, err := db.Exec(ctx, `
+		INSERT INTO idempotency_keys (key, request_hash, request_path, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key, request_path) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+			response_code = NULL,
+			response_body = NULL,
+			expires_at = EXCLUDED.expires_at,
+			created_at = NOW()
+		WHERE idempotency_keys.expires_at <= NOW()`,
+		k.Key, k.RequestHash, k.RequestPath, k.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert idempotency key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrKeyUsed
+	}
+	return nil
+}
+
+// Forget deletes k's unfinished row in the caller's transaction, for a request
+// whose business write committed but then failed in a way the caller has
+// undone. Retries with the key then run again instead of getting ErrKeyUsed.
+// A row with a recorded response is kept.
+func Forget(ctx context.Context, db Execer, k *model.IdempotencyKey) error {
+	_, err := db.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE key = $1 AND request_path = $2 AND response_body IS NULL`,
+		k.Key, k.RequestPath)
+	if err != nil {
+		return fmt.Errorf("failed to forget idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Complete stores the response of a committed request in Postgres and Redis
+// for replay. Failures are logged rather than returned: the business write has
+// already committed, and its Postgres key still blocks a repeat.
+func (s *Store) Complete(ctx context.Context, k *model.IdempotencyKey, resp *Response) {
+	resp.Header = replayHeader(resp.Header)
+	k.ResponseCode, k.ResponseBody, k.ResponseHeaders = resp.StatusCode, resp.Body, resp.Header
+
+	// Routes whose handler does not Insert the key get their row here.
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO idempotency_keys (key, request_hash, request_path, expires_at, response_code, response_body, response_headers)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (key, request_path) DO UPDATE
+		SET response_code = EXCLUDED.response_code,
+			response_body = EXCLUDED.response_body,
+			response_headers = EXCLUDED.response_headers`,
+		k.Key, k.RequestHash, k.RequestPath, k.ExpiresAt, resp.StatusCode, resp.Body, resp.Header)
+	if err != nil {
+		s.log.Error().Err(err).Str("idempotency_key", k.Key).Msg("Failed to store idempotent response in Postgres")
+	}
+
+	if err := s.redis.MarkIdempotencyComplete(ctx, redisKey(k), s.result(k, resp), s.ttl); err != nil {
+		s.log.Warn().Err(err).Str("idempotency_key", k.Key).Msg("Failed to store idempotent response in Redis")
+	}
+}
+
+// Release drops the Redis claim of a request that did not complete, so it can
+// be retried with the same key. It does nothing after Complete, so callers can
+// defer it. Postgres is left alone: a failed business write rolled its key
+// back, and one that committed must stay recorded.
+func (s *Store) Release(ctx context.Context, k *model.IdempotencyKey) {
+	if k.ResponseCode != 0 {
+		return
+	}
+	s.release(ctx, k)
+}
+
+func (s *Store) release(ctx context.Context, k *model.IdempotencyKey) {
+	if err := s.redis.MarkIdempotencyFailed(ctx, redisKey(k)); err != nil {
+		s.log.Warn().Err(err).Str("idempotency_key", k.Key).Msg("Failed to release idempotency key in Redis")
+	}
+}
@@ -0,0 +1,86 @@
+package idempotency
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	base := Fingerprint("POST", "/api/v1/transactions/payout", []byte(`{"amount":100}`))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		same   bool
+	}{
+		{name: "identical request", method: "POST", path: "/api/v1/transactions/payout", body: `{"amount":100}`, same: true},
+		{name: "different method", method: "PUT", path: "/api/v1/transactions/payout", body: `{"amount":100}`},
+		{name: "different path", method: "POST", path: "/api/v1/transactions/payment-intent", body: `{"amount":100}`},
+		{name: "different body", method: "POST", path: "/api/v1/transactions/payout", body: `{"amount":101}`},
+		{name: "reformatted body", method: "POST", path: "/api/v1/transactions/payout", body: `{"amount": 100}`},
+		{name: "empty body", method: "POST", path: "/api/v1/transactions/payout"},
+		{name: "field boundary shifted", method: "POST", path: "/api/v1/transactions/payout{", body: `"amount":100}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Fingerprint(tt.method, tt.path, []byte(tt.body))
+			if len(got) != 64 {
+				t.Fatalf("Fingerprint() = %q, want 64 hex characters", got)
+			}
+			if (got == base) != tt.same {
+				t.Errorf("Fingerprint() == base is %v, want %v", got == base, tt.same)
+			}
+		})
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	body := `{"amount":100}`
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr error
+	}{
+		{name: "with key", key: "k1"},
+		{name: "missing key", wantErr: ErrMissingKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/v1/transactions/payout", strings.NewReader(body))
+			if tt.key != "" {
+				r.Header.Set(Header, tt.key)
+			}
+
+			k, err := FromRequest(r)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("FromRequest() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromRequest() error = %v", err)
+			}
+			if k.Key != tt.key || k.RequestPath != "/api/v1/transactions/payout" {
+				t.Errorf("FromRequest() key = %q path = %q", k.Key, k.RequestPath)
+			}
+			if want := Fingerprint("POST", "/api/v1/transactions/payout", []byte(body)); k.RequestHash != want {
+				t.Errorf("FromRequest() hash = %q, want %q", k.RequestHash, want)
+			}
+			if k.UserID != nil {
+				t.Errorf("FromRequest() user = %v, want nil", k.UserID)
+			}
+			if rest, _ := io.ReadAll(r.Body); string(rest) != body {
+				t.Errorf("request body after FromRequest = %q, want %q", rest, body)
+			}
+		})
+	}
+}
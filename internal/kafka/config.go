@@ -3,6 +3,7 @@ package kafka
 import (
 	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
@@ -25,9 +26,13 @@ const (
 
 // Event types for outbox
 const (
-	EventPaymentIntentCreated = "aegis.payment.created"
-	EventWebhookReceived      = "aegis.webhook.received"
-	EventLedgerEntryCreated   = "aegis.ledger.entry.created"
+	EventPaymentIntentCreated    = "aegis.payment.created"
+	EventWebhookReceived         = "aegis.webhook.received"
+	EventLedgerEntryCreated      = "aegis.ledger.entry.created"
+	EventPayoutRequested         = "aegis.payout.requested"
+	EventPayoutStatusUpdated     = "aegis.payout.status.updated"
+	EventDiscrepancyDetected     = "aegis.discrepancy.detected"
+	EventReconciliationRequested = "aegis.reconciliation.requested"
 )
 
 // ConsumerGroup names for different Kafka consumers
@@ -38,6 +43,7 @@ const (
 	GroupWebhookWorker     = "aegis.webhook.worker"
 	GroupPayoutWorker      = "aegis.payout.worker"
 	GroupReconciliation    = "aegis.reconciliation.worker"
+	GroupDLQReplay         = "aegis.dlq.replay"
 )
 
 type Config struct {
@@ -49,6 +55,11 @@ type Config struct {
 	MaxPollRecords    int
 	MaxRetries        int
 	RetryBackoff      time.Duration
+	// ConcurrentPartitions gives each assigned partition its own goroutine, so
+	// a slow message only holds up its own partition.
+	ConcurrentPartitions bool
+	// Logger receives consumer errors. Nil logs JSON to stderr.
+	Logger *zerolog.Logger
 }
 
 func DefaultConfig(brokers []string) *Config {
@@ -3,6 +3,7 @@ package kafka
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/Niiaks/Aegis/internal/middleware"
@@ -18,42 +19,98 @@ type Message struct {
 	Offset    int64
 	Timestamp time.Time
 	Headers   map[string]string
+	// Group is the consumer group the message was read by.
+	Group string
 }
 
+func newMessage(record *kgo.Record, group string) *Message {
+	return &Message{
+		Topic:     record.Topic,
+		Key:       record.Key,
+		Value:     record.Value,
+		Partition: record.Partition,
+		Offset:    record.Offset,
+		Timestamp: record.Timestamp,
+		Headers:   headersToMap(record.Headers),
+		Group:     group,
+	}
+}
+
+// dlqRetryMin and dlqRetryMax bound the backoff between attempts to publish a
+// failed message to the DLQ.
+const (
+	dlqRetryMin = time.Second
+	dlqRetryMax = time.Minute
+)
+
 // Handler processes a single message. Return error to trigger retry.
 type Handler func(ctx context.Context, msg *Message) error
 
 type Consumer struct {
+	*monitor
+
 	client *kgo.Client
 	cfg    *Config
-	topic  string
+	topics []string
 	group  string
+
+	// Set when cfg.ConcurrentPartitions is enabled.
+	partitions *partitionWorkers
 }
 
-func NewConsumer(cfg *Config, group, topic string) (*Consumer, error) {
-	client, err := kgo.NewClient(
+// NewConsumer joins group and subscribes to every given topic. Pair it with a
+// Router to dispatch each topic or event type to its own handler.
+func NewConsumer(cfg *Config, group string, topics ...string) (*Consumer, error) {
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("kafka consumer %s: at least one topic is required", group)
+	}
+	c := &Consumer{
+		monitor: newMonitor(cfg, group),
+		cfg:     cfg,
+		topics:  topics,
+		group:   group,
+	}
+
+	opts := []kgo.Opt{
 		kgo.SeedBrokers(cfg.Brokers...),
 		kgo.ConsumerGroup(group),
-		kgo.ConsumeTopics(topic),
+		kgo.ConsumeTopics(topics...),
 		kgo.SessionTimeout(cfg.SessionTimeout),
 		kgo.HeartbeatInterval(cfg.HeartbeatInterval),
 		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()), // Start from earliest if no offset
-	)
+		// Only records that were handled or dead-lettered are marked, so
+		// nothing is committed past a record still in flight.
+		kgo.AutoCommitMarks(),
+	}
+	if cfg.ConcurrentPartitions {
+		c.partitions = newPartitionWorkers(c)
+		opts = append(opts,
+			kgo.OnPartitionsAssigned(c.partitions.assigned),
+			kgo.OnPartitionsRevoked(c.partitions.revoked),
+			kgo.OnPartitionsLost(c.partitions.lost),
+		)
+	}
+
+	client, err := kgo.NewClient(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kafka consumer: %w", err)
 	}
+	c.client = client
+	c.monitor.client = client
 
-	return &Consumer{
-		client: client,
-		cfg:    cfg,
-		topic:  topic,
-		group:  group,
-	}, nil
+	return c, nil
 }
 
 // Run starts consuming messages and calls handler for each.
 // Blocks until context is cancelled.
 func (c *Consumer) Run(ctx context.Context, handler Handler) error {
+	c.running.Store(true)
+	defer c.running.Store(false)
+
+	if c.partitions != nil {
+		return c.runConcurrent(ctx, handler)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -62,54 +119,76 @@ func (c *Consumer) Run(ctx context.Context, handler Handler) error {
 		}
 
 		fetches := c.client.PollFetches(ctx)
-		if errs := fetches.Errors(); len(errs) > 0 {
-			// Log errors but continue - transient errors are common
-			for _, err := range errs {
-				fmt.Printf("fetch error: topic=%s partition=%d err=%v\n",
-					err.Topic, err.Partition, err.Err)
+		c.recordFetchErrors(fetches)
+
+		for iter := fetches.RecordIter(); !iter.Done(); {
+			record := iter.Next()
+			if !c.processRecord(ctx, handler, record) {
+				break
 			}
+			c.client.MarkCommitRecords(record)
 		}
 
-		fetches.EachRecord(func(record *kgo.Record) {
-			headers := headersToMap(record.Headers)
-			ctx := ctx
-			if reqID, ok := headers["X-Request-ID"]; ok {
-				ctx = middleware.WithRequestID(ctx, reqID)
-			}
+		// Commit offsets after processing batch, even when shutting down
+		if err := c.client.CommitMarkedOffsets(context.WithoutCancel(ctx)); err != nil {
+			c.log.Error().Err(err).Msg("Failed to commit offsets")
+		}
+	}
+}
 
-			msg := &Message{
-				Topic:     record.Topic,
-				Key:       record.Key,
-				Value:     record.Value,
-				Partition: record.Partition,
-				Offset:    record.Offset,
-				Timestamp: record.Timestamp,
-				Headers:   headers,
+// processRecord runs handler with retries and sends the message to the DLQ if
+// it still fails, retrying the DLQ publish until it succeeds. It reports false
+// only when ctx was cancelled first, in which case the record must not be
+// committed.
+func (c *Consumer) processRecord(ctx context.Context, handler Handler, record *kgo.Record) bool {
+	msg := newMessage(record, c.group)
+	if reqID, ok := msg.Headers["X-Request-ID"]; ok {
+		ctx = middleware.WithRequestID(ctx, reqID)
+	}
+
+	err := c.processWithRetry(ctx, handler, msg)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		log := c.log.With().Str("topic", msg.Topic).Int32("partition", msg.Partition).Int64("offset", msg.Offset).Logger()
+		log.Error().Err(err).Msg("Message processing failed after retries, sending to DLQ")
+		// The record is only committed once it is in the DLQ, so block its
+		// partition rather than lose it.
+		for backoff := dlqRetryMin; ; backoff = min(backoff*2, dlqRetryMax) {
+			dlqErr := c.publishToDLQ(ctx, msg, err)
+			if dlqErr == nil {
+				break
 			}
-			if err := c.processWithRetry(ctx, handler, msg); err != nil {
-				fmt.Printf("message processing failed after retries: %v\n", err)
-				// Send to DLQ
-				fmt.Printf("Sending to DLQ: %v\n", msg)
-				if dlqErr := c.publishToDLQ(ctx, msg); dlqErr != nil {
-					fmt.Printf("failed to publish to DLQ: %v\n", dlqErr)
-				}
+			log.Error().Err(dlqErr).Dur("retry_in", backoff).Msg("Failed to publish to DLQ")
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(backoff):
 			}
-		})
-
-		// Commit offsets after processing batch
-		if err := c.client.CommitUncommittedOffsets(ctx); err != nil {
-			fmt.Printf("failed to commit offsets: %v\n", err)
 		}
 	}
+	c.recordDone(msg, err != nil)
+	return true
 }
 
 func (c *Consumer) processWithRetry(ctx context.Context, handler Handler, msg *Message) error {
+	return retry(ctx, c.cfg, func() error {
+		err := handler(ctx, msg)
+		c.recordAttempt(msg, err)
+		return err
+	})
+}
+
+// retry calls fn up to MaxRetries+1 times with exponential backoff between
+// attempts.
+func retry(ctx context.Context, cfg *Config, fn func() error) error {
 	var lastErr error
 
-	for attempt := 0; attempt <= c.cfg.MaxRetries; attempt++ {
+	for attempt := 0; attempt <= cfg.MaxRetries; attempt++ {
 		if attempt > 0 {
 			// Exponential backoff:
-			backoff := c.cfg.RetryBackoff * time.Duration(1<<(attempt-1))
+			backoff := cfg.RetryBackoff * time.Duration(1<<(attempt-1))
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
@@ -117,7 +196,7 @@ func (c *Consumer) processWithRetry(ctx context.Context, handler Handler, msg *M
 			}
 		}
 
-		if err := handler(ctx, msg); err != nil {
+		if err := fn(); err != nil {
 			lastErr = err
 			continue
 		}
@@ -126,18 +205,40 @@ func (c *Consumer) processWithRetry(ctx context.Context, handler Handler, msg *M
 
 	return fmt.Errorf("max retries exceeded: %w", lastErr)
 }
-func (c *Consumer) publishToDLQ(ctx context.Context, msg *Message) error {
-	record := &kgo.Record{
-		Topic: TopicDLQ,
-		Key:   msg.Key,
-		Value: msg.Value,
-	}
+
+// publishToDLQ forwards a message that exhausted its retries to the DLQ with
+// its own headers plus the failure context described in ADR-007.
+func (c *Consumer) publishToDLQ(ctx context.Context, msg *Message, cause error) error {
+	record := dlqRecord(msg, cause, c.cfg.MaxRetries+1)
 
 	// Use ProduceSync to wait for the result
 	results := c.client.ProduceSync(ctx, record)
 	return results.FirstErr()
 }
 
+func dlqRecord(msg *Message, cause error, attempts int) *kgo.Record {
+	headers := make(map[string]string, len(msg.Headers)+9)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderOriginalTopic] = msg.Topic
+	headers[HeaderOriginalPartition] = strconv.FormatInt(int64(msg.Partition), 10)
+	headers[HeaderOriginalOffset] = strconv.FormatInt(msg.Offset, 10)
+	headers[HeaderOriginalTimestamp] = msg.Timestamp.UTC().Format(time.RFC3339Nano)
+	headers[HeaderConsumerGroup] = msg.Group
+	headers[HeaderSource] = DLQSourceConsumer
+	headers[HeaderError] = cause.Error()
+	headers[HeaderAttempts] = strconv.Itoa(attempts)
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+
+	return &kgo.Record{
+		Topic:   TopicDLQ,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: mapToHeaders(headers),
+	}
+}
+
 func (c *Consumer) Close() {
 	c.client.Close()
 }
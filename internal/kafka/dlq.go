@@ -0,0 +1,212 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Headers describing why and where a message was dead-lettered. They are added
+// next to the message's own headers (X-Request-ID and so on), which are kept
+// unchanged. The format is documented in ADR-007.
+const (
+	// HeaderOriginalTopic names the topic the message was meant for; replay
+	// sends it back there.
+	HeaderOriginalTopic     = "original-topic"
+	HeaderOriginalPartition = "original-partition"
+	HeaderOriginalOffset    = "original-offset"
+	// HeaderOriginalTimestamp is the source record's timestamp, RFC 3339.
+	HeaderOriginalTimestamp = "original-timestamp"
+	HeaderConsumerGroup     = "consumer-group"
+	// HeaderSource is "consumer" or "outbox-relay".
+	HeaderSource   = "dlq-source"
+	HeaderError    = "error"
+	HeaderAttempts = "attempts"
+	// HeaderFailedAt is when the message was dead-lettered, RFC 3339.
+	HeaderFailedAt = "failed-at"
+	// Set only by the outbox relay.
+	HeaderOutboxID = "outbox-id"
+
+	// HeaderReplayedAt is set on messages republished from the DLQ.
+	HeaderReplayedAt = "dlq-replayed-at"
+)
+
+const (
+	DLQSourceConsumer    = "consumer"
+	DLQSourceOutboxRelay = "outbox-relay"
+)
+
+// dlqHeaders are dropped when a message is replayed so that, if it fails
+// again, the new DLQ record describes the new failure only.
+var dlqHeaders = []string{
+	HeaderOriginalTopic, HeaderOriginalPartition, HeaderOriginalOffset, HeaderOriginalTimestamp,
+	HeaderConsumerGroup, HeaderSource, HeaderError, HeaderAttempts, HeaderFailedAt,
+	HeaderOutboxID,
+}
+
+// replayIdleTimeout is how long a replay waits for more DLQ records before
+// deciding it has caught up.
+const replayIdleTimeout = 5 * time.Second
+
+var (
+	ErrUnroutable = errors.New("dlq message has no original topic")
+	// ErrReplayInProgress means this process is already replaying the DLQ.
+	ErrReplayInProgress = errors.New("dlq replay already in progress")
+)
+
+type ReplayOptions struct {
+	// Limit caps how many messages are replayed; 0 means no cap.
+	Limit int
+	// Topic overrides the original-topic header, for messages dead-lettered
+	// before that header existed.
+	Topic string
+	// DryRun reports what would be replayed without producing or committing.
+	DryRun bool
+}
+
+type ReplayedMessage struct {
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Key       string `json:"key"`
+	Topic     string `json:"topic"`
+}
+
+type ReplayResult struct {
+	Replayed []ReplayedMessage `json:"replayed"`
+	DryRun   bool              `json:"dry_run"`
+}
+
+// DLQReplayer republishes messages from the DLQ to their original topics. It
+// consumes as its own group and commits only what it has republished, so each
+// dead-lettered message is replayed at most once per successful run. Runs are
+// serialized: a replay requested while another is running fails with
+// ErrReplayInProgress instead of joining the group alongside it.
+type DLQReplayer struct {
+	cfg *Config
+	mu  sync.Mutex
+}
+
+func NewDLQReplayer(cfg *Config) *DLQReplayer {
+	return &DLQReplayer{cfg: cfg}
+}
+
+// Replay drains the DLQ until it is caught up or opts.Limit is reached. It stops
+// at the first message it cannot route, leaving it and everything after it in
+// that partition for a later run.
+func (d *DLQReplayer) Replay(ctx context.Context, opts ReplayOptions) (*ReplayResult, error) {
+	if !d.mu.TryLock() {
+		return nil, ErrReplayInProgress
+	}
+	defer d.mu.Unlock()
+
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(d.cfg.Brokers...),
+		kgo.ConsumerGroup(GroupDLQReplay),
+		kgo.ConsumeTopics(TopicDLQ),
+		kgo.DisableAutoCommit(),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.RequiredAcks(kgo.Acks(d.cfg.RequiredAcks)),
+		kgo.ProduceRequestTimeout(d.cfg.ProducerTimeout),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dlq replay client: %w", err)
+	}
+	defer client.Close()
+
+	result := &ReplayResult{Replayed: []ReplayedMessage{}, DryRun: opts.DryRun}
+	for opts.Limit == 0 || len(result.Replayed) < opts.Limit {
+		pollCtx, cancel := context.WithTimeout(ctx, replayIdleTimeout)
+		maxRecords := d.cfg.MaxPollRecords
+		if opts.Limit > 0 {
+			maxRecords = min(maxRecords, opts.Limit-len(result.Replayed))
+		}
+		fetches := client.PollRecords(pollCtx, maxRecords)
+		cancel()
+
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		for _, fe := range fetches.Errors() {
+			if errors.Is(fe.Err, context.DeadlineExceeded) || errors.Is(fe.Err, context.Canceled) {
+				continue
+			}
+			return result, fmt.Errorf("failed to fetch dlq: topic=%s partition=%d: %w", fe.Topic, fe.Partition, fe.Err)
+		}
+
+		records := fetches.Records()
+		if len(records) == 0 {
+			return result, nil // caught up
+		}
+
+		var replayed []*kgo.Record
+		var routeErr error
+		for _, record := range records {
+			topic := opts.Topic
+			if topic == "" {
+				topic = headerValue(record.Headers, HeaderOriginalTopic)
+			}
+			if topic == "" || topic == TopicDLQ {
+				routeErr = fmt.Errorf("%w: partition=%d offset=%d", ErrUnroutable, record.Partition, record.Offset)
+				break
+			}
+
+			if !opts.DryRun {
+				out := &kgo.Record{
+					Topic:   topic,
+					Key:     record.Key,
+					Value:   record.Value,
+					Headers: replayHeaders(record.Headers),
+				}
+				if err := client.ProduceSync(ctx, out).FirstErr(); err != nil {
+					routeErr = fmt.Errorf("failed to republish partition=%d offset=%d: %w", record.Partition, record.Offset, err)
+					break
+				}
+			}
+
+			replayed = append(replayed, record)
+			result.Replayed = append(result.Replayed, ReplayedMessage{
+				Partition: record.Partition,
+				Offset:    record.Offset,
+				Key:       string(record.Key),
+				Topic:     topic,
+			})
+		}
+
+		if !opts.DryRun && len(replayed) > 0 {
+			if err := client.CommitRecords(ctx, replayed...); err != nil {
+				return result, fmt.Errorf("failed to commit replayed dlq offsets: %w", err)
+			}
+		}
+		if routeErr != nil || opts.DryRun {
+			// A dry run never commits, so polling again would only see the same records.
+			return result, routeErr
+		}
+	}
+	return result, nil
+}
+
+func headerValue(headers []kgo.RecordHeader, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// replayHeaders strips the DLQ context from a dead-lettered record's headers
+// and marks it as replayed.
+func replayHeaders(headers []kgo.RecordHeader) []kgo.RecordHeader {
+	out := make([]kgo.RecordHeader, 0, len(headers)+1)
+	for _, h := range headers {
+		if !slices.Contains(dlqHeaders, h.Key) && h.Key != HeaderReplayedAt {
+			out = append(out, h)
+		}
+	}
+	return append(out, kgo.RecordHeader{Key: HeaderReplayedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))})
+}
@@ -0,0 +1,121 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/rs/zerolog"
+)
+
+// Middleware wraps a Handler with cross-cutting behaviour.
+type Middleware func(Handler) Handler
+
+// Chain wraps h so that the first middleware is the outermost.
+func Chain(h Handler, mw ...Middleware) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// Recover turns a panicking handler into an error, so the message is retried
+// and dead-lettered instead of crashing the worker.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					err = fmt.Errorf("handler panic: %v\n%s", p, debug.Stack())
+				}
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
+
+// Logging puts a logger carrying the message coordinates into the context,
+// where middleware.GetLogger finds it, and logs each attempt's outcome.
+func Logging(logger *zerolog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			log := logger.With().
+				Str("topic", msg.Topic).
+				Int32("partition", msg.Partition).
+				Int64("offset", msg.Offset).
+				Str("event_type", msg.Headers[HeaderEventType]).
+				Str("request_id", middleware.GetRequestIDFromContext(ctx)).
+				Logger()
+			ctx = context.WithValue(ctx, middleware.LoggerKey, &log)
+
+			start := time.Now()
+			err := next(ctx, msg)
+			event := log.Debug()
+			if err != nil {
+				event = log.Error().Err(err)
+			}
+			event.Dur("duration", time.Since(start)).Msg("Handled message")
+			return err
+		}
+	}
+}
+
+// Tracing records each message as a New Relic background transaction and puts
+// it in the context. A nil app disables it.
+func Tracing(app *newrelic.Application) Middleware {
+	return func(next Handler) Handler {
+		if app == nil {
+			return next
+		}
+		return func(ctx context.Context, msg *Message) error {
+			txn := app.StartTransaction("kafka/" + msg.Topic)
+			defer txn.End()
+			txn.AddAttribute("messaging.kafka.partition", int(msg.Partition))
+			txn.AddAttribute("messaging.kafka.offset", msg.Offset)
+			txn.AddAttribute("event_type", msg.Headers[HeaderEventType])
+			txn.AddAttribute("request_id", middleware.GetRequestIDFromContext(ctx))
+
+			err := next(newrelic.NewContext(ctx, txn), msg)
+			if err != nil {
+				txn.NoticeError(err)
+			}
+			return err
+		}
+	}
+}
+
+// Metrics records per-topic counts and durations of handled and failed
+// attempts as New Relic custom metrics. A nil app disables it.
+func Metrics(app *newrelic.Application) Middleware {
+	return func(next Handler) Handler {
+		if app == nil {
+			return next
+		}
+		return func(ctx context.Context, msg *Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+
+			outcome := "handled"
+			if err != nil {
+				outcome = "failed"
+			}
+			app.RecordCustomMetric("Kafka/"+msg.Topic+"/"+outcome, 1)
+			app.RecordCustomMetric("Kafka/"+msg.Topic+"/duration_ms", float64(time.Since(start).Milliseconds()))
+			return err
+		}
+	}
+}
+
+// Timeout bounds each handler attempt. Retries get a fresh timeout.
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, msg)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// partitionBuffer is how many fetched batches may queue for one partition
+// before fetching from it is paused. It resumes once half the queue drains.
+const partitionBuffer = 8
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// partitionWorker processes one partition's records in order.
+type partitionWorker struct {
+	tp     topicPartition
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	// ready wakes the worker when records are queued.
+	ready chan struct{}
+
+	mu     sync.Mutex
+	queue  [][]*kgo.Record
+	paused bool
+}
+
+// partitionWorkers owns a goroutine per assigned partition. Records are marked
+// for commit only after they are handled (or dead-lettered), and marked
+// offsets are committed periodically and when partitions are revoked.
+type partitionWorkers struct {
+	consumer *Consumer
+
+	mu      sync.Mutex
+	ctx     context.Context
+	handler Handler
+	// owned holds the partitions assigned to this member. Workers are started
+	// on their first records, once Run has set ctx and handler.
+	owned   map[topicPartition]bool
+	workers map[topicPartition]*partitionWorker
+}
+
+func newPartitionWorkers(c *Consumer) *partitionWorkers {
+	return &partitionWorkers{
+		consumer: c,
+		owned:    make(map[topicPartition]bool),
+		workers:  make(map[topicPartition]*partitionWorker),
+	}
+}
+
+func (c *Consumer) runConcurrent(ctx context.Context, handler Handler) error {
+	p := c.partitions
+	p.mu.Lock()
+	p.ctx, p.handler = ctx, handler
+	p.mu.Unlock()
+
+	defer func() {
+		// Handlers see the cancelled context; commit whatever was handled.
+		p.stop(p.all())
+		if err := c.client.CommitMarkedOffsets(context.Background()); err != nil {
+			c.log.Error().Err(err).Msg("Failed to commit offsets")
+		}
+	}()
+
+	for {
+		fetches := c.client.PollFetches(ctx)
+		if ctx.Err() != nil || fetches.IsClientClosed() {
+			return ctx.Err()
+		}
+		c.recordFetchErrors(fetches)
+
+		fetches.EachPartition(func(fp kgo.FetchTopicPartition) {
+			if len(fp.Records) > 0 {
+				p.dispatch(topicPartition{fp.Topic, fp.Partition}, fp.Records)
+			}
+		})
+	}
+}
+
+// dispatch queues records for their partition's worker, starting it if
+// needed. It never blocks: a partition whose queue is full is paused so
+// polling carries on for the others. Records for a partition that has since
+// been revoked are dropped; they were never marked, so the partition's new
+// owner will process them.
+func (p *partitionWorkers) dispatch(tp topicPartition, records []*kgo.Record) {
+	w := p.worker(tp)
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	if w.ctx.Err() != nil {
+		// Stopped since it was looked up.
+		w.mu.Unlock()
+		return
+	}
+	w.queue = append(w.queue, records)
+	if len(w.queue) >= partitionBuffer && !w.paused {
+		w.paused = true
+		p.consumer.client.PauseFetchPartitions(map[string][]int32{tp.topic: {tp.partition}})
+	}
+	w.mu.Unlock()
+
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+}
+
+// worker returns the worker for tp, starting one if the partition is owned.
+func (p *partitionWorkers) worker(tp topicPartition) *partitionWorker {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if w, ok := p.workers[tp]; ok {
+		return w
+	}
+	if !p.owned[tp] || p.ctx == nil {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	w := &partitionWorker{
+		tp:     tp,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		ready:  make(chan struct{}, 1),
+	}
+	p.workers[tp] = w
+	go p.work(w, p.handler)
+	return w
+}
+
+func (p *partitionWorkers) assigned(_ context.Context, _ *kgo.Client, assigned map[string][]int32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for topic, partitions := range assigned {
+		for _, partition := range partitions {
+			p.owned[topicPartition{topic, partition}] = true
+		}
+	}
+}
+
+func (p *partitionWorkers) work(w *partitionWorker, handler Handler) {
+	defer close(w.done)
+	for {
+		records, ok := p.next(w)
+		if !ok {
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-w.ready:
+				continue
+			}
+		}
+		for _, record := range records {
+			if w.ctx.Err() != nil {
+				return
+			}
+			if !p.consumer.processRecord(w.ctx, handler, record) {
+				return
+			}
+			p.consumer.client.MarkCommitRecords(record)
+		}
+	}
+}
+
+// next pops the worker's oldest batch, resuming the partition once its queue
+// has drained to half.
+func (p *partitionWorkers) next(w *partitionWorker) ([]*kgo.Record, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.queue) == 0 {
+		return nil, false
+	}
+	records := w.queue[0]
+	w.queue[0] = nil
+	w.queue = w.queue[1:]
+	if w.paused && len(w.queue) <= partitionBuffer/2 {
+		w.paused = false
+		p.consumer.client.ResumeFetchPartitions(map[string][]int32{w.tp.topic: {w.tp.partition}})
+	}
+	return records, true
+}
+
+// revoked stops the workers for partitions leaving this member and commits
+// what they handled before the partitions are reassigned.
+func (p *partitionWorkers) revoked(ctx context.Context, cl *kgo.Client, revoked map[string][]int32) {
+	p.stop(p.remove(revoked))
+	if err := cl.CommitMarkedOffsets(ctx); err != nil {
+		p.consumer.log.Error().Err(err).Msg("Failed to commit offsets on revoke")
+	}
+}
+
+// lost stops the workers without committing; another member may already own
+// the partitions.
+func (p *partitionWorkers) lost(_ context.Context, _ *kgo.Client, lost map[string][]int32) {
+	p.stop(p.remove(lost))
+}
+
+func (p *partitionWorkers) remove(partitions map[string][]int32) []*partitionWorker {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var removed []*partitionWorker
+	for topic, ps := range partitions {
+		for _, partition := range ps {
+			tp := topicPartition{topic, partition}
+			delete(p.owned, tp)
+			if w, ok := p.workers[tp]; ok {
+				removed = append(removed, w)
+				delete(p.workers, tp)
+			}
+		}
+	}
+	return removed
+}
+
+func (p *partitionWorkers) all() []*partitionWorker {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	workers := make([]*partitionWorker, 0, len(p.workers))
+	for tp, w := range p.workers {
+		workers = append(workers, w)
+		delete(p.workers, tp)
+	}
+	return workers
+}
+
+// stop cancels the workers' contexts, interrupting any message in flight, and
+// waits for them. Interrupted and queued records are left unmarked, so they
+// are redelivered. Paused partitions are resumed so a later assignment of them
+// fetches again.
+func (p *partitionWorkers) stop(workers []*partitionWorker) {
+	for _, w := range workers {
+		w.cancel()
+	}
+	for _, w := range workers {
+		<-w.done
+
+		w.mu.Lock()
+		if w.paused {
+			w.paused = false
+			p.consumer.client.ResumeFetchPartitions(map[string][]int32{w.tp.topic: {w.tp.partition}})
+		}
+		w.queue = nil
+		w.mu.Unlock()
+	}
+}
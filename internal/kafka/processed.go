@@ -0,0 +1,26 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// MarkProcessed records msg in processed_messages as part of tx and reports
+// whether this is the first time the consumer group has seen it. A handler
+// that writes to Postgres calls it first in its transaction and, on false,
+// rolls back and returns nil: the message was already applied and is being
+// redelivered (offsets are committed after the handler, so a crash in between
+// replays it). The row commits or rolls back with the handler's own writes.
+func MarkProcessed(ctx context.Context, tx pgx.Tx, msg *Message) (bool, error) {
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO processed_messages (consumer_group, topic, kafka_partition, kafka_offset)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING`,
+		msg.Group, msg.Topic, msg.Partition, msg.Offset)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark message processed: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
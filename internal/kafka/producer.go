@@ -50,6 +50,21 @@ func (p *Producer) PublishWithHeaders(ctx context.Context, topic string, key, va
 	return results
 }
 
+// PublishWithCallback produces a message without waiting for the broker and
+// calls onDone with the delivery result. onDone runs on the client's callback
+// goroutine, so it must not block or produce.
+func (p *Producer) PublishWithCallback(ctx context.Context, topic string, key, value []byte, headers map[string]string, onDone func(error)) {
+	record := &kgo.Record{
+		Topic:   topic,
+		Key:     key,
+		Value:   value,
+		Headers: mapToHeaders(headers),
+	}
+	p.client.Produce(ctx, record, func(_ *kgo.Record, err error) {
+		onDone(err)
+	})
+}
+
 func mapToHeaders(m map[string]string) []kgo.RecordHeader {
 	if len(m) == 0 {
 		return nil
@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// HeaderEventType carries the outbox event type on every message the relay
+// publishes. Router uses it to dispatch messages that share a topic.
+const HeaderEventType = "event_type"
+
+var ErrNoRoute = errors.New("no handler registered for message")
+
+// Router dispatches messages to handlers by event_type header or by topic. A
+// handler registered for the message's event type wins over one registered for
+// its topic; messages matching neither go to the NotFound handler, or fail with
+// ErrNoRoute (and so end up in the DLQ) when none is set.
+type Router struct {
+	events     map[string]Handler
+	topics     map[string]Handler
+	notFound   Handler
+	middleware []Middleware
+}
+
+func NewRouter() *Router {
+	return &Router{
+		events: make(map[string]Handler),
+		topics: make(map[string]Handler),
+	}
+}
+
+// Use appends middleware wrapped around every routed handler. The first
+// middleware added is the outermost.
+func (r *Router) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+func (r *Router) HandleTopic(topic string, h Handler) {
+	r.topics[topic] = h
+}
+
+func (r *Router) HandleEvent(eventType string, h Handler) {
+	r.events[eventType] = h
+}
+
+func (r *Router) NotFound(h Handler) {
+	r.notFound = h
+}
+
+// Topics lists, sorted, the topics with a registered handler, for subscribing
+// the consumer. Topics carrying only event-routed messages must be added by the
+// caller.
+func (r *Router) Topics() []string {
+	topics := make([]string, 0, len(r.topics))
+	for topic := range r.topics {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+	return topics
+}
+
+// Handler returns the router as a single Handler for Consumer.Run.
+func (r *Router) Handler() Handler {
+	return Chain(r.route, r.middleware...)
+}
+
+func (r *Router) route(ctx context.Context, msg *Message) error {
+	if h, ok := r.events[msg.Headers[HeaderEventType]]; ok {
+		return h(ctx, msg)
+	}
+	if h, ok := r.topics[msg.Topic]; ok {
+		return h(ctx, msg)
+	}
+	if r.notFound != nil {
+		return r.notFound(ctx, msg)
+	}
+	return fmt.Errorf("%w: topic=%s event_type=%q", ErrNoRoute, msg.Topic, msg.Headers[HeaderEventType])
+}
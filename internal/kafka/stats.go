@@ -0,0 +1,185 @@
+package kafka
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// PartitionStats describes one partition this consumer has processed, plus its
+// group-wide lag when reported by Stats.
+type PartitionStats struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	// LastOffset is the last offset handled or dead-lettered; -1 if none.
+	LastOffset      int64      `json:"last_processed_offset"`
+	LastProcessedAt *time.Time `json:"last_processed_at,omitempty"`
+	Processed       int64      `json:"processed"`
+	// Errors counts failed handler attempts, including ones later retried.
+	Errors int64 `json:"errors"`
+	DLQ    int64 `json:"dlq"`
+
+	CommittedOffset int64 `json:"committed_offset"`
+	EndOffset       int64 `json:"end_offset"`
+	// Lag is EndOffset - CommittedOffset, or -1 if either is unknown.
+	Lag int64 `json:"lag"`
+}
+
+// ConsumerStats is a snapshot of a consumer's progress for health reporting.
+type ConsumerStats struct {
+	Group      string           `json:"group"`
+	Running    bool             `json:"running"`
+	Partitions []PartitionStats `json:"partitions"`
+	Processed  int64            `json:"processed"`
+	Errors     int64            `json:"errors"`
+	DLQ        int64            `json:"dlq"`
+	// ErrorRate is failed attempts over all attempts since start.
+	ErrorRate      float64 `json:"error_rate"`
+	TotalLag       int64   `json:"total_lag"`
+	LastFetchError string  `json:"last_fetch_error,omitempty"`
+	// LagError is set when lag could not be fetched from the brokers.
+	LagError string `json:"lag_error,omitempty"`
+}
+
+// monitor records what a consumer processes and reports it, with lag from the
+// group's committed offsets. Both consumer types embed one.
+type monitor struct {
+	group  string
+	client *kgo.Client
+	log    zerolog.Logger
+
+	running atomic.Bool
+
+	mu             sync.Mutex
+	partitions     map[topicPartition]*PartitionStats
+	lastFetchError string
+}
+
+func newMonitor(cfg *Config, group string) *monitor {
+	log := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	if cfg.Logger != nil {
+		log = *cfg.Logger
+	}
+	return &monitor{
+		group:      group,
+		log:        log.With().Str("consumer_group", group).Logger(),
+		partitions: make(map[topicPartition]*PartitionStats),
+	}
+}
+
+func (m *monitor) partition(topic string, partition int32) *PartitionStats {
+	tp := topicPartition{topic, partition}
+	p, ok := m.partitions[tp]
+	if !ok {
+		p = &PartitionStats{Topic: topic, Partition: partition, LastOffset: -1}
+		m.partitions[tp] = p
+	}
+	return p
+}
+
+func (m *monitor) recordFetchErrors(fetches kgo.Fetches) {
+	for _, fe := range fetches.Errors() {
+		// Log errors but continue - transient errors are common
+		m.log.Warn().Err(fe.Err).Str("topic", fe.Topic).Int32("partition", fe.Partition).Msg("Kafka fetch error")
+		m.mu.Lock()
+		m.lastFetchError = fmt.Sprintf("%s[%d]: %v", fe.Topic, fe.Partition, fe.Err)
+		m.mu.Unlock()
+	}
+}
+
+func (m *monitor) recordAttempt(msg *Message, err error) {
+	if err == nil {
+		return
+	}
+	m.mu.Lock()
+	m.partition(msg.Topic, msg.Partition).Errors++
+	m.mu.Unlock()
+}
+
+func (m *monitor) recordDone(msg *Message, dlq bool) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.partition(msg.Topic, msg.Partition)
+	p.LastOffset = msg.Offset
+	p.LastProcessedAt = &now
+	if dlq {
+		p.DLQ++
+	} else {
+		p.Processed++
+	}
+}
+
+// Running reports whether Run is consuming.
+func (m *monitor) Running() bool {
+	return m.running.Load()
+}
+
+// Ping checks that a broker is reachable.
+func (m *monitor) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx)
+}
+
+// Stats returns the consumer's counters with per-partition lag for the whole
+// group, including partitions owned by other members. Lag errors are reported
+// in the result rather than failing it.
+func (m *monitor) Stats(ctx context.Context) ConsumerStats {
+	m.mu.Lock()
+	stats := ConsumerStats{
+		Group:          m.group,
+		Running:        m.running.Load(),
+		LastFetchError: m.lastFetchError,
+	}
+	byPartition := make(map[topicPartition]PartitionStats, len(m.partitions))
+	for tp, p := range m.partitions {
+		byPartition[tp] = *p
+	}
+	m.mu.Unlock()
+
+	lags, err := kadm.NewClient(m.client).Lag(ctx, m.group)
+	if err == nil {
+		err = lags.Error()
+	}
+	if err != nil {
+		stats.LagError = err.Error()
+	}
+	for _, l := range lags[m.group].Lag.Sorted() {
+		tp := topicPartition{l.Topic, l.Partition}
+		p, ok := byPartition[tp]
+		if !ok {
+			p = PartitionStats{Topic: l.Topic, Partition: l.Partition, LastOffset: -1}
+		}
+		p.CommittedOffset, p.EndOffset, p.Lag = l.Commit.At, l.End.Offset, l.Lag
+		byPartition[tp] = p
+	}
+
+	for _, p := range byPartition {
+		if _, ok := lags[m.group].Lag.Lookup(p.Topic, p.Partition); !ok {
+			p.CommittedOffset, p.EndOffset, p.Lag = -1, -1, -1
+		}
+		stats.Partitions = append(stats.Partitions, p)
+		stats.Processed += p.Processed
+		stats.Errors += p.Errors
+		stats.DLQ += p.DLQ
+		if p.Lag > 0 {
+			stats.TotalLag += p.Lag
+		}
+	}
+	slices.SortFunc(stats.Partitions, func(a, b PartitionStats) int {
+		return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+	})
+	if attempts := stats.Processed + stats.Errors; attempts > 0 {
+		stats.ErrorRate = float64(stats.Errors) / float64(attempts)
+	}
+	return stats
+}
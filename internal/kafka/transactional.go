@@ -0,0 +1,191 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// TxOutput collects the records a TxHandler wants to produce. They are
+// produced only once the handler succeeds, in the same Kafka transaction that
+// commits the consumed offsets.
+type TxOutput struct {
+	records []*kgo.Record
+}
+
+func (o *TxOutput) Produce(topic string, key, value []byte, headers map[string]string) {
+	o.records = append(o.records, &kgo.Record{
+		Topic:   topic,
+		Key:     key,
+		Value:   value,
+		Headers: mapToHeaders(headers),
+	})
+}
+
+// TxHandler processes a message inside a consume-transform-produce
+// transaction. Output produced on a failed attempt is discarded.
+type TxHandler func(ctx context.Context, msg *Message, out *TxOutput) error
+
+// WithoutOutput adapts a Handler that produces nothing itself, such as a
+// Router's, so it gets atomic DLQ publishing and offset commits.
+func WithoutOutput(h Handler) TxHandler {
+	return func(ctx context.Context, msg *Message, _ *TxOutput) error {
+		return h(ctx, msg)
+	}
+}
+
+// TransactionalConsumer consumes with franz-go's GroupTransactSession: each
+// polled batch's produced records (handler output and DLQ records) and its
+// offset commit succeed or fail together, and it reads only committed records.
+// If anything in the batch cannot be produced, or the group rebalances
+// mid-batch, the transaction aborts and the batch is redelivered.
+//
+// Exactly-once covers Kafka only. A handler that also writes to Postgres must
+// make that write idempotent, e.g. with MarkProcessed.
+type TransactionalConsumer struct {
+	*monitor
+
+	sess  *kgo.GroupTransactSession
+	cfg   *Config
+	group string
+}
+
+// NewTransactionalConsumer joins group and subscribes to topics. transactionalID
+// must be stable for a worker instance and unique across instances.
+func NewTransactionalConsumer(cfg *Config, group, transactionalID string, topics ...string) (*TransactionalConsumer, error) {
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("kafka transactional consumer %s: at least one topic is required", group)
+	}
+
+	sess, err := kgo.NewGroupTransactSession(
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.TransactionalID(transactionalID),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+		kgo.RequireStableFetchOffsets(),
+		kgo.ConsumerGroup(group),
+		kgo.ConsumeTopics(topics...),
+		kgo.SessionTimeout(cfg.SessionTimeout),
+		kgo.HeartbeatInterval(cfg.HeartbeatInterval),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.ProduceRequestTimeout(cfg.ProducerTimeout),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka transactional consumer: %w", err)
+	}
+
+	c := &TransactionalConsumer{
+		monitor: newMonitor(cfg, group),
+		sess:    sess,
+		cfg:     cfg,
+		group:   group,
+	}
+	c.monitor.client = sess.Client()
+	return c, nil
+}
+
+// Run consumes until ctx is cancelled or the transaction enters a state it
+// cannot recover from.
+func (c *TransactionalConsumer) Run(ctx context.Context, handler TxHandler) error {
+	c.running.Store(true)
+	defer c.running.Store(false)
+
+	for {
+		fetches := c.sess.PollFetches(ctx)
+		if ctx.Err() != nil || fetches.IsClientClosed() {
+			return ctx.Err()
+		}
+		c.recordFetchErrors(fetches)
+		if fetches.NumRecords() == 0 {
+			continue
+		}
+
+		if err := c.sess.Begin(); err != nil {
+			return fmt.Errorf("failed to begin kafka transaction: %w", err)
+		}
+
+		var mu sync.Mutex
+		var produceErr error
+		promise := func(_ *kgo.Record, err error) {
+			if err != nil {
+				mu.Lock()
+				produceErr = errors.Join(produceErr, err)
+				mu.Unlock()
+			}
+		}
+
+		interrupted := false
+		fetches.EachRecord(func(record *kgo.Record) {
+			if interrupted {
+				return
+			}
+			records, ok := c.process(ctx, handler, record)
+			if !ok {
+				interrupted = true
+				return
+			}
+			for _, r := range records {
+				c.sess.Produce(ctx, r, promise)
+			}
+		})
+
+		// Wait for every record so a failed produce aborts instead of commits.
+		if err := c.sess.Client().Flush(ctx); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to flush kafka transaction: %w", err)
+		}
+		mu.Lock()
+		try := kgo.TryCommit
+		if interrupted || produceErr != nil {
+			try = kgo.TryAbort
+		}
+		mu.Unlock()
+
+		committed, err := c.sess.End(context.WithoutCancel(ctx), try)
+		if err != nil {
+			return fmt.Errorf("failed to end kafka transaction: %w", err)
+		}
+		if !committed && produceErr != nil {
+			c.log.Warn().Err(produceErr).Msg("Kafka transaction aborted, batch will be redelivered")
+		}
+		if interrupted {
+			return ctx.Err()
+		}
+	}
+}
+
+// process runs handler with retries and returns the records to produce for
+// the message: its output on success, or a DLQ record once retries are
+// exhausted. It reports false when ctx was cancelled first.
+func (c *TransactionalConsumer) process(ctx context.Context, handler TxHandler, record *kgo.Record) ([]*kgo.Record, bool) {
+	msg := newMessage(record, c.group)
+	if reqID, ok := msg.Headers["X-Request-ID"]; ok {
+		ctx = middleware.WithRequestID(ctx, reqID)
+	}
+
+	var out *TxOutput
+	err := retry(ctx, c.cfg, func() error {
+		out = &TxOutput{}
+		err := handler(ctx, msg, out)
+		c.recordAttempt(msg, err)
+		return err
+	})
+	if err == nil {
+		c.recordDone(msg, false)
+		return out.records, true
+	}
+	if ctx.Err() != nil {
+		return nil, false
+	}
+
+	c.log.Error().Err(err).Str("topic", msg.Topic).Int32("partition", msg.Partition).Int64("offset", msg.Offset).
+		Msg("Message processing failed after retries, sending to DLQ")
+	c.recordDone(msg, true)
+	return []*kgo.Record{dlqRecord(msg, err, c.cfg.MaxRetries+1)}, true
+}
+
+func (c *TransactionalConsumer) Close() {
+	c.sess.Close()
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/Niiaks/Aegis/internal/server"
+)
+
+// Admin guards operator routes with a shared bearer token.
+type Admin struct {
+	token string
+}
+
+func NewAdmin(s *server.Server) *Admin {
+	return &Admin{
+		token: s.Config.Admin.Token,
+	}
+}
+
+// Require rejects requests without the admin bearer token. When no token is
+// configured the routes it guards are disabled and answer 404.
+func (a *Admin) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.token == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			GetLogger(r.Context()).Warn().Str("path", r.URL.Path).Msg("Rejected admin request without a valid token")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="aegis-admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Niiaks/Aegis/internal/idempotency"
+	"github.com/Niiaks/Aegis/internal/server"
+)
+
+// Idempotency makes mutating routes safe to retry. Mount it on the routes that
+// need it; requests with a method outside the configured ones pass through.
+type Idempotency struct {
+	store   *idempotency.Store
+	methods []string
+}
+
+func NewIdempotency(s *server.Server) *Idempotency {
+	cfg := s.Config.Idempotency
+	methods := make([]string, len(cfg.Methods))
+	for i, m := range cfg.Methods {
+		methods[i] = strings.ToUpper(strings.TrimSpace(m))
+	}
+	return &Idempotency{
+		store:   idempotency.NewStore(s.Db.Pool, s.Redis(), s.Logger, cfg.TTL),
+		methods: methods,
+	}
+}
+
+// Require enforces the Idempotency-Key header. A retry of a completed request
+// gets the original response replayed; a key reused with a different request
+// gets 422, and one whose request is still in flight gets 409. Otherwise the
+// handler runs with the key in its context (see idempotency.FromContext), and
+// a 2xx response is stored for replay before it is sent. Any other response
+// releases the key so the client can retry.
+func (i *Idempotency) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(i.methods, r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		logger := GetLogger(ctx)
+
+		idem, err := idempotency.FromRequest(r)
+		if errors.Is(err, idempotency.ErrMissingKey) {
+			logger.Error().Msg("Idempotency-Key header is missing")
+			http.Error(w, "Idempotency-Key header is required", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to read request body")
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		replay, err := i.store.Begin(ctx, idem)
+		switch {
+		case replay != nil:
+			logger.Info().Str("idempotency_key", idem.Key).Msg("Replaying response for idempotency key")
+			replay.Write(w)
+			return
+		case errors.Is(err, idempotency.ErrFingerprintMismatch):
+			logger.Warn().Str("idempotency_key", idem.Key).Msg("Idempotency key reused with a different request")
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, idempotency.ErrInProgress), errors.Is(err, idempotency.ErrKeyUsed):
+			logger.Warn().Err(err).Str("idempotency_key", idem.Key).Msg("Idempotency key not available")
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			logger.Error().Err(err).Msg("Idempotency check failed")
+			http.Error(w, "Idempotency check failed", http.StatusInternalServerError)
+			return
+		}
+		defer i.store.Release(ctx, idem)
+
+		rec := &recordingWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(idempotency.NewContext(ctx, idem)))
+
+		// Store before sending, so a client that retries as soon as it has
+		// the response is replayed rather than told the request is in flight.
+		if rec.statusCode >= 200 && rec.statusCode < 300 {
+			i.store.Complete(ctx, idem, &idempotency.Response{
+				StatusCode: rec.statusCode,
+				Header:     w.Header(),
+				Body:       rec.body.Bytes(),
+			})
+		}
+		rec.flush()
+	})
+}
+
+// recordingWriter buffers a response so it can be stored before it is sent.
+// Headers go straight to the wrapped writer's map.
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.statusCode = code
+	rw.wroteHeader = true
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.body.Write(b)
+}
+
+func (rw *recordingWriter) flush() {
+	rw.ResponseWriter.WriteHeader(rw.statusCode)
+	rw.ResponseWriter.Write(rw.body.Bytes())
+}
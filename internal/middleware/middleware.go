@@ -9,6 +9,9 @@ type Middlewares struct {
 	Global          *Global
 	ContextEnhancer *ContextEnhancer
 	Tracing         *TracingMiddleware
+	Idempotency     *Idempotency
+	RateLimit       *RateLimit
+	Admin           *Admin
 }
 
 func NewMiddlewares(s *server.Server) *Middlewares {
@@ -23,5 +26,8 @@ func NewMiddlewares(s *server.Server) *Middlewares {
 		Global:          NewGlobal(s),
 		ContextEnhancer: NewContextEnhancer(s),
 		Tracing:         NewTracing(nrApp),
+		Idempotency:     NewIdempotency(s),
+		RateLimit:       NewRateLimit(s),
+		Admin:           NewAdmin(s),
 	}
 }
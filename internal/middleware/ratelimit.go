@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/config"
+	"github.com/Niiaks/Aegis/internal/redis"
+	"github.com/Niiaks/Aegis/internal/server"
+	"github.com/go-chi/chi/v5"
+)
+
+// RateLimit enforces the per-route and per-IP policies from
+// config. Every applicable policy is checked; the one closest to its limit is
+// reported in the RateLimit-* headers.
+type RateLimit struct {
+	redis *redis.Client
+	cfg   config.RateLimitConfig
+}
+
+func NewRateLimit(s *server.Server) *RateLimit {
+	return &RateLimit{
+		redis: s.Redis(),
+		cfg:   s.Config.RateLimit,
+	}
+}
+
+// rateLimitExempt are paths never rate limited. Paystack retries webhooks on
+// its own schedule, so a 429 or 503 would only delay settlement.
+var rateLimitExempt = map[string]bool{
+	"/api/v1/paystack/webhook": true,
+}
+
+// rateLimitCheck is one policy applied to one request.
+type rateLimitCheck struct {
+	key    string
+	policy config.RateLimitPolicy
+}
+
+// Limit returns the middleware. routes resolves each request's chi route
+// pattern for the per-route policies, since top-level middleware runs before
+// routing; pass the router the middleware is mounted on.
+func (rl *RateLimit) Limit(routes chi.Routes) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !rl.cfg.Enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rateLimitExempt[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			logger := GetLogger(ctx)
+
+			client := "ip:" + clientIP(r)
+			checks := []rateLimitCheck{{client, rl.cfg.IP}}
+			if pattern := routes.Find(chi.NewRouteContext(), r.Method, r.URL.Path); pattern != "" {
+				if policy, ok := rl.cfg.Routes[r.Method+" "+pattern]; ok {
+					checks = append(checks, rateLimitCheck{"route:" + r.Method + " " + pattern + ":" + client, policy})
+				}
+			}
+
+			var tightest *redis.RateLimitResult
+			var tightestLimit int64
+			for _, c := range checks {
+				if c.policy.Limit <= 0 {
+					continue
+				}
+				res, err := rl.check(ctx, c)
+				if err != nil {
+					if rl.cfg.FailOpen {
+						logger.Warn().Err(err).Msg("Rate limiter unavailable, allowing request")
+						next.ServeHTTP(w, r)
+						return
+					}
+					logger.Error().Err(err).Msg("Rate limiter unavailable, rejecting request")
+					http.Error(w, "Rate limiter unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				if tightest == nil || !res.Allowed || (res.Remaining >= 0 && (tightest.Remaining < 0 || res.Remaining < tightest.Remaining)) {
+					tightest, tightestLimit = res, c.policy.Limit
+				}
+				if !res.Allowed {
+					logger.Warn().Str("ratelimit_key", c.key).Msg("Rate limit exceeded")
+					break
+				}
+			}
+
+			if tightest != nil {
+				reset := max(int64(math.Ceil(time.Until(tightest.ResetAt).Seconds())), 0)
+				w.Header().Set("RateLimit-Limit", strconv.FormatInt(tightestLimit, 10))
+				if tightest.Remaining >= 0 {
+					w.Header().Set("RateLimit-Remaining", strconv.FormatInt(tightest.Remaining, 10))
+				}
+				w.Header().Set("RateLimit-Reset", strconv.FormatInt(reset, 10))
+				if !tightest.Allowed {
+					w.Header().Set("Retry-After", strconv.FormatInt(reset, 10))
+					http.Error(w, redis.ErrRateLimitExceeded.Error(), http.StatusTooManyRequests)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (rl *RateLimit) check(ctx context.Context, c rateLimitCheck) (*redis.RateLimitResult, error) {
+	if rl.cfg.Algorithm == "fixed" {
+		allowed, err := rl.redis.SimpleRateLimit(ctx, c.key, c.policy.Limit, c.policy.Window)
+		if err != nil {
+			return nil, err
+		}
+		// The fixed window limiter does not report its count, so the remaining
+		// budget is unknown (-1) until it rejects.
+		remaining := int64(-1)
+		if !allowed {
+			remaining = 0
+		}
+		return &redis.RateLimitResult{Allowed: allowed, Remaining: remaining, ResetAt: time.Now().Add(c.policy.Window)}, nil
+	}
+	return rl.redis.CheckRateLimit(ctx, c.key, c.policy.Limit, c.policy.Window)
+}
+
+// clientIP is the host part of RemoteAddr, which the router rewrites from
+// proxy headers when AEGIS_SERVER_TRUST_PROXY is set.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -38,20 +38,21 @@ type LedgerEntry struct {
 	Debit         int64     `json:"debit" validate:"gte=0"`
 	Credit        int64     `json:"credit" validate:"gte=0"`
 	BalanceAfter  int64     `json:"balance_after" validate:"gte=0"`
-	Description   string    `json:"description" validate:"required,oneof=revenue,payout,fee,refund"`
+	Description   string    `json:"description" validate:"required,oneof=revenue payout fee refund adjustment"`
 	Model
 }
 
 type Transaction struct {
-	ID             uuid.UUID `json:"id"`
-	IdempotencyKey string    `json:"idempotency_key" validate:"required"`
-	UserID         uuid.UUID `json:"user_id" validate:"required"`
-	Amount         int64     `json:"amount" validate:"required,gte=0"`
-	Currency       string    `json:"currency" validate:"required,len=3"`
-	PspReference   string    `json:"psp_reference"`
-	Status         string    `json:"status" validate:"required,oneof=pending completed failed refunded"`
-	Type           string    `json:"type" validate:"required,oneof=payment_intent payout refund fee"`
-	FailureReason  string    `json:"failure_reason,omitempty"`
+	ID                  uuid.UUID  `json:"id"`
+	IdempotencyKey      string     `json:"idempotency_key" validate:"required"`
+	UserID              uuid.UUID  `json:"user_id" validate:"required"`
+	Amount              int64      `json:"amount" validate:"required,gte=0"`
+	Currency            string     `json:"currency" validate:"required,len=3"`
+	PspReference        string     `json:"psp_reference"`
+	Status              string     `json:"status" validate:"required,oneof=pending completed failed refunded"`
+	Type                string     `json:"type" validate:"required,oneof=payment_intent payout refund fee adjustment"`
+	FailureReason       string     `json:"failure_reason,omitempty"`
+	ParentTransactionID *uuid.UUID `json:"parent_transaction_id,omitempty"`
 	Model
 }
 
@@ -64,7 +65,7 @@ type TransactionOutbox struct {
 	CorrelationID uuid.UUID       `json:"correlation_id" validate:"required"`
 	RetryCount    int             `json:"retry_count" validate:"gte=0"`
 	LastError     string          `json:"last_error,omitempty"`
-	NextRetryAt   time.Time       `json:"next_retry_at"`
+	NextRetryAt   *time.Time      `json:"next_retry_at,omitempty"`
 	MaxRetries    int             `json:"max_retries" validate:"gte=0"`
 	Model
 }
@@ -78,30 +79,42 @@ type PspWebhook struct {
 }
 
 type ReconciliationRun struct {
-	ID      uuid.UUID `json:"id"`
-	RunDate time.Time `json:"run_date" validate:"required"`
-	Status  string    `json:"status" validate:"required,oneof=discrepancy matched"`
+	ID         uuid.UUID `json:"id"`
+	RunDate    time.Time `json:"run_date" validate:"required"`
+	Status     string    `json:"status" validate:"required,oneof=discrepancy matched"`
+	Source     string    `json:"source" validate:"required,oneof=api settlement_file"`
+	SourceFile string    `json:"source_file,omitempty"`
 	Model
 }
 
 type Discrepancy struct {
-	ID                  uuid.UUID `json:"id"`
-	ReconciliationRunID uuid.UUID `json:"reconciliation_run_id" validate:"required"`
-	ExpectedAmount      int64     `json:"expected_amount" validate:"required"`
-	ActualAmount        int64     `json:"actual_amount" validate:"required"`
-	Reason              string    `json:"reason" validate:"required"`
-	Status              string    `json:"status" validate:"required,oneof=unresolved resolved"`
+	ID                  uuid.UUID  `json:"id"`
+	ReconciliationRunID uuid.UUID  `json:"reconciliation_run_id" validate:"required"`
+	TransactionID       *uuid.UUID `json:"transaction_id,omitempty"`
+	PspReference        string     `json:"psp_reference,omitempty"`
+	ExpectedAmount      int64      `json:"expected_amount" validate:"required"`
+	ActualAmount        int64      `json:"actual_amount" validate:"required"`
+	ReasonCode          string     `json:"reason_code" validate:"required"`
+	Reason              string     `json:"reason" validate:"required"`
+	Status              string     `json:"status" validate:"required,oneof=unresolved resolved"`
+	AssignedTo          string     `json:"assigned_to,omitempty"`
+	ResolutionType      string     `json:"resolution_type,omitempty" validate:"omitempty,oneof=manual_adjustment psp_error timing_difference duplicate no_action"`
+	ResolutionNote      string     `json:"resolution_note,omitempty"`
+	ResolvedBy          string     `json:"resolved_by,omitempty"`
+	ResolvedAt          *time.Time `json:"resolved_at,omitempty"`
+	AdjustmentTxID      *uuid.UUID `json:"adjustment_transaction_id,omitempty"`
 	Model
 }
 
 type IdempotencyKey struct {
-	ID           uuid.UUID       `json:"id"`
-	Key          string          `json:"key" validate:"required"`
-	RequestHash  string          `json:"request_hash" validate:"required,len=64"`
-	RequestPath  string          `json:"request_path" validate:"required"`
-	ResponseCode int             `json:"response_code,omitempty"`
-	ResponseBody json.RawMessage `json:"response_body,omitempty"`
-	UserID       *uuid.UUID      `json:"user_id,omitempty"`
-	ExpiresAt    time.Time       `json:"expires_at" validate:"required"`
-	CreatedAt    time.Time       `json:"created_at"`
+	ID              uuid.UUID           `json:"id"`
+	Key             string              `json:"key" validate:"required"`
+	RequestHash     string              `json:"request_hash" validate:"required,len=64"`
+	RequestPath     string              `json:"request_path" validate:"required"`
+	ResponseCode    int                 `json:"response_code,omitempty"`
+	ResponseBody    json.RawMessage     `json:"response_body,omitempty"`
+	ResponseHeaders map[string][]string `json:"response_headers,omitempty"`
+	UserID          *uuid.UUID          `json:"user_id,omitempty"`
+	ExpiresAt       time.Time           `json:"expires_at" validate:"required"`
+	CreatedAt       time.Time           `json:"created_at"`
 }
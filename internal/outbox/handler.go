@@ -0,0 +1,170 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Niiaks/Aegis/internal/kafka"
+	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = validator.New()
+
+// ReplayRequest bounds a replay triggered over HTTP; use the CLI for larger
+// drains.
+type ReplayRequest struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=1000"`
+	Topic  string `json:"topic"`
+	DryRun bool   `json:"dry_run"`
+}
+
+type OutboxHandler struct {
+	service *OutboxService
+}
+
+func NewOutboxHandler(service *OutboxService) *OutboxHandler {
+	return &OutboxHandler{
+		service: service,
+	}
+}
+
+func (oh *OutboxHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	q := r.URL.Query()
+	filter := ListFilter{
+		Status:        q.Get("status"),
+		EventType:     q.Get("event_type"),
+		CorrelationID: q.Get("correlation_id"),
+		Limit:         50,
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := q.Get("cursor"); v != "" {
+		before, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		filter.BeforeID = before
+	}
+
+	if err := validate.Struct(&filter); err != nil {
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := oh.service.ListEvents(ctx, &filter)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list outbox events")
+		http.Error(w, "Failed to list outbox events", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (oh *OutboxHandler) GetEvent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid event id", http.StatusBadRequest)
+		return
+	}
+
+	event, err := oh.service.GetEvent(ctx, id)
+	switch {
+	case errors.Is(err, ErrEventNotFound):
+		http.Error(w, "Outbox event not found", http.StatusNotFound)
+		return
+	case err != nil:
+		logger.Error().Err(err).Int64("event_id", id).Msg("Failed to get outbox event")
+		http.Error(w, "Failed to get outbox event", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+func (oh *OutboxHandler) ResetEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	var req ResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode reset request")
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := validate.Struct(&req); err != nil {
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := oh.service.ResetEvents(ctx, req.IDs)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to reset outbox events")
+		http.Error(w, "Failed to reset outbox events", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (oh *OutboxHandler) ReplayDLQ(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	req := ReplayRequest{Limit: 100}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode replay request")
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := validate.Struct(&req); err != nil {
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := oh.service.ReplayDLQ(ctx, kafka.ReplayOptions{
+		Limit:  req.Limit,
+		Topic:  req.Topic,
+		DryRun: req.DryRun,
+	})
+	switch {
+	case errors.Is(err, kafka.ErrReplayInProgress):
+		http.Error(w, "A DLQ replay is already running", http.StatusConflict)
+		return
+	case errors.Is(err, kafka.ErrUnroutable):
+		// Report what was replayed before the message that could not be routed.
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error(), "result": res})
+		return
+	case err != nil:
+		logger.Error().Err(err).Msg("Failed to replay DLQ")
+		http.Error(w, "Failed to replay DLQ", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
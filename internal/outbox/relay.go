@@ -2,35 +2,59 @@ package outbox
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"strconv"
 	"time"
 
+	"github.com/Niiaks/Aegis/internal/config"
 	"github.com/Niiaks/Aegis/internal/kafka"
 	"github.com/Niiaks/Aegis/internal/model"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 )
 
+// notifyChannel must match the channel the transaction_outbox insert trigger
+// notifies.
+const notifyChannel = "transaction_outbox"
+
 type Relay struct {
-	db          *pgxpool.Pool
-	kafkaClient *kafka.Producer
-	logger      *zerolog.Logger
-	batchSize   int
-	interval    time.Duration
+	db               *pgxpool.Pool
+	kafkaClient      *kafka.Producer
+	logger           *zerolog.Logger
+	mode             string
+	batchSize        int
+	interval         time.Duration
+	fallbackInterval time.Duration
+	baseBackoff      time.Duration
+	maxBackoff       time.Duration
 }
 
-func NewRelay(db *pgxpool.Pool, kafkaClient *kafka.Producer, logger *zerolog.Logger) *Relay {
+func NewRelay(db *pgxpool.Pool, kafkaClient *kafka.Producer, logger *zerolog.Logger, cfg config.OutboxConfig) *Relay {
 	return &Relay{
-		db:          db,
-		kafkaClient: kafkaClient,
-		logger:      logger,
-		batchSize:   500,                    // Process 500 events at a time
-		interval:    100 * time.Millisecond, // Poll every 100ms for high throughput
+		db:               db,
+		kafkaClient:      kafkaClient,
+		logger:           logger,
+		mode:             cfg.Mode,
+		batchSize:        cfg.BatchSize,
+		interval:         cfg.PollInterval,
+		fallbackInterval: cfg.FallbackInterval,
+		baseBackoff:      time.Second,
+		maxBackoff:       5 * time.Minute,
 	}
 }
 
 func (r *Relay) Start(ctx context.Context) error {
-	r.logger.Info().Msg("Starting Outbox Relay")
+	r.logger.Info().Str("mode", r.mode).Msg("Starting Outbox Relay")
+	if r.mode == "listen" {
+		return r.listen(ctx)
+	}
+	return r.poll(ctx)
+}
+
+func (r *Relay) poll(ctx context.Context) error {
 	ticker := time.NewTicker(r.interval)
 	defer ticker.Stop()
 
@@ -40,73 +64,147 @@ func (r *Relay) Start(ctx context.Context) error {
 			r.logger.Info().Msg("Stopping Outbox Relay")
 			return nil
 		case <-ticker.C:
-			if err := r.processBatch(ctx); err != nil {
+			if _, err := r.processBatch(ctx); err != nil {
 				r.logger.Error().Err(err).Msg("Failed to process batch")
 			}
 		}
 	}
 }
 
-func (r *Relay) processBatch(ctx context.Context) error {
+// listen drains the outbox whenever the insert trigger notifies, and at least
+// every fallbackInterval so retries that come due and any missed notification
+// are still picked up. If the listening connection is lost the relay keeps
+// polling at the fallback interval until it can reconnect.
+func (r *Relay) listen(ctx context.Context) error {
+	for {
+		conn, err := r.connectListener(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				r.logger.Info().Msg("Stopping Outbox Relay")
+				return nil
+			}
+			r.logger.Error().Err(err).Msg("Failed to listen for outbox notifications, polling until reconnected")
+		}
+
+		for {
+			r.drain(ctx)
+
+			if conn == nil {
+				select {
+				case <-ctx.Done():
+					r.logger.Info().Msg("Stopping Outbox Relay")
+					return nil
+				case <-time.After(r.fallbackInterval):
+				}
+				break // try to reconnect
+			}
+
+			waitCtx, cancel := context.WithTimeout(ctx, r.fallbackInterval)
+			_, err := conn.WaitForNotification(waitCtx)
+			cancel()
+
+			if ctx.Err() != nil {
+				conn.Close(context.Background())
+				r.logger.Info().Msg("Stopping Outbox Relay")
+				return nil
+			}
+			if err != nil && (!errors.Is(err, context.DeadlineExceeded) || conn.IsClosed()) {
+				r.logger.Error().Err(err).Msg("Lost outbox notification connection")
+				conn.Close(context.Background())
+				break
+			}
+		}
+	}
+}
+
+// connectListener opens a connection outside the pool, since LISTEN holds it
+// for the lifetime of the relay.
+func (r *Relay) connectListener(ctx context.Context) (*pgx.Conn, error) {
+	conn, err := pgx.ConnectConfig(ctx, r.db.Config().ConnConfig.Copy())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+	return conn, nil
+}
+
+// drain processes batches until the outbox has no more due events.
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := r.processBatch(ctx)
+		if err != nil {
+			r.logger.Error().Err(err).Msg("Failed to process batch")
+			return
+		}
+		if n < r.batchSize {
+			return
+		}
+	}
+}
+
+// processBatch publishes one batch of due events and returns how many were
+// fetched.
+func (r *Relay) processBatch(ctx context.Context) (int, error) {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer tx.Rollback(ctx)
 
 	//lock rows for updating while skipping any rows that are already locked by another transaction
 	rows, err := tx.Query(ctx, `
-		SELECT id, event_type, payload, partition_key, correlation_id
-		FROM transaction_outbox
-		WHERE status = 'pending'
+		SELECT id, event_type, payload, partition_key, correlation_id, retry_count, max_retries
+		FROM transaction_outbox o
+		WHERE status = 'pending' AND (next_retry_at IS NULL OR next_retry_at <= NOW())
+			-- hold back a key's newer events while an older one waits to be retried
+			AND NOT EXISTS (
+				SELECT 1 FROM transaction_outbox earlier
+				WHERE earlier.partition_key = o.partition_key
+					AND earlier.status = 'pending'
+					AND earlier.id < o.id
+					AND earlier.next_retry_at > NOW()
+			)
 		ORDER BY id ASC
 		LIMIT $1
 		FOR UPDATE SKIP LOCKED
 	`, r.batchSize)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	var events []model.TransactionOutbox
 	for rows.Next() {
 		var e model.TransactionOutbox
-		if err := rows.Scan(&e.ID, &e.EventType, &e.Payload, &e.PartitionKey, &e.CorrelationID); err != nil {
+		if err := rows.Scan(&e.ID, &e.EventType, &e.Payload, &e.PartitionKey, &e.CorrelationID, &e.RetryCount, &e.MaxRetries); err != nil {
 			rows.Close()
-			return err
+			return 0, err
 		}
 		events = append(events, e)
 	}
 	rows.Close()
 
 	if len(events) == 0 {
-		return nil
+		return 0, nil
 	}
 
 	if len(events) > 0 {
 		r.logger.Info().Int("count", len(events)).Msg("Fetched outbox events")
 	}
 
-	var processedIDs []int64
-	for _, e := range events {
-		topic := r.getTopicForEvent(e.EventType)
-		fmt.Println("the topic is", topic)
-
-		headers := map[string]string{
-			"X-Request-ID": e.CorrelationID.String(),
+	processedIDs, failures := r.publish(ctx, events)
+	for _, f := range failures {
+		e := events[f.index]
+		r.logger.Error().Err(f.err).Int64("event_id", e.ID).Str("event_type", e.EventType).Int("retry_count", e.RetryCount).Msg("Failed to publish event to Kafka")
+		if err := r.recordFailure(ctx, tx, e, r.getTopicForEvent(e.EventType), f.err); err != nil {
+			return 0, err
 		}
-
-		r.logger.Debug().Str("topic", topic).Msg("Publishing to Kafka...")
-		err := r.kafkaClient.PublishWithHeaders(ctx, topic, []byte(e.PartitionKey), e.Payload, headers)
-
-		if err != nil {
-			r.logger.Error().Err(err).Int64("event_id", e.ID).Str("event_type", e.EventType).Msg("Failed to publish event to Kafka")
-			continue // Do not mark as processed
-		}
-		processedIDs = append(processedIDs, e.ID)
 	}
 
 	if len(processedIDs) == 0 {
-		return nil // Nothing to update
+		return len(events), tx.Commit(ctx) // Persist any recorded failures
 	}
 
 	_, err = tx.Exec(ctx, `
@@ -115,10 +213,130 @@ func (r *Relay) processBatch(ctx context.Context) error {
 		       WHERE id = ANY($1)
 	       `, processedIDs)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	return len(events), tx.Commit(ctx)
+}
+
+type publishResult struct {
+	index int
+	err   error
+}
+
+// publish produces the batch asynchronously and returns the IDs Kafka
+// acknowledged along with the events that failed. Events sharing a partition
+// key are chained: the next one is produced only once the previous one is
+// acknowledged, so a key's events can never overtake each other, while different
+// keys are in flight concurrently. After a failure the rest of that key's chain
+// is left pending untouched for a later batch.
+func (r *Relay) publish(ctx context.Context, events []model.TransactionOutbox) ([]int64, []publishResult) {
+	chains := make(map[string][]int)
+	var keys []string
+	for i, e := range events {
+		if _, ok := chains[e.PartitionKey]; !ok {
+			keys = append(keys, e.PartitionKey)
+		}
+		chains[e.PartitionKey] = append(chains[e.PartitionKey], i)
+	}
+
+	// Buffered for every event so delivery callbacks never block.
+	results := make(chan publishResult, len(events))
+	produce := func(i int) {
+		e := events[i]
+		topic := r.getTopicForEvent(e.EventType)
+		headers := map[string]string{
+			"X-Request-ID":        e.CorrelationID.String(),
+			kafka.HeaderEventType: e.EventType,
+		}
+		r.logger.Debug().Str("topic", topic).Int64("event_id", e.ID).Msg("Publishing to Kafka...")
+		r.kafkaClient.PublishWithCallback(ctx, topic, []byte(e.PartitionKey), e.Payload, headers, func(err error) {
+			results <- publishResult{index: i, err: err}
+		})
+	}
+
+	inFlight := 0
+	for _, key := range keys {
+		produce(chains[key][0])
+		inFlight++
+	}
+
+	var processedIDs []int64
+	var failures []publishResult
+	next := make(map[string]int, len(keys))
+	for inFlight > 0 {
+		res := <-results
+		inFlight--
+
+		if res.err != nil {
+			failures = append(failures, res)
+			continue
+		}
+		processedIDs = append(processedIDs, events[res.index].ID)
+
+		key := events[res.index].PartitionKey
+		next[key]++
+		if chain := chains[key]; next[key] < len(chain) {
+			produce(chain[next[key]])
+			inFlight++
+		}
+	}
+
+	return processedIDs, failures
+}
+
+// recordFailure stores the publish error on the event and schedules the next
+// attempt. Once max_retries is exhausted the event is moved to the DLQ topic and
+// its row is marked dlq so the relay stops picking it up.
+func (r *Relay) recordFailure(ctx context.Context, tx pgx.Tx, e model.TransactionOutbox, topic string, publishErr error) error {
+	retryCount := e.RetryCount + 1
+
+	if retryCount > e.MaxRetries {
+		headers := map[string]string{
+			"X-Request-ID":            e.CorrelationID.String(),
+			kafka.HeaderOriginalTopic: topic,
+			kafka.HeaderSource:        kafka.DLQSourceOutboxRelay,
+			kafka.HeaderEventType:     e.EventType,
+			kafka.HeaderOutboxID:      strconv.FormatInt(e.ID, 10),
+			kafka.HeaderAttempts:      strconv.Itoa(retryCount),
+			kafka.HeaderError:         publishErr.Error(),
+			kafka.HeaderFailedAt:      time.Now().UTC().Format(time.RFC3339),
+		}
+		dlqErr := r.kafkaClient.PublishWithHeaders(ctx, kafka.TopicDLQ, []byte(e.PartitionKey), e.Payload, headers)
+		if dlqErr == nil {
+			r.logger.Warn().Int64("event_id", e.ID).Str("event_type", e.EventType).Int("retry_count", retryCount).Msg("Moved outbox event to DLQ")
+			_, err := tx.Exec(ctx, `
+				UPDATE transaction_outbox
+				SET status = 'dlq', retry_count = $2, last_error = $3, next_retry_at = NULL, updated_at = NOW()
+				WHERE id = $1
+			`, e.ID, retryCount, publishErr.Error())
+			return err
+		}
+		// The DLQ is unreachable too; keep the event pending and try again later.
+		r.logger.Error().Err(dlqErr).Int64("event_id", e.ID).Msg("Failed to publish event to DLQ")
+		publishErr = fmt.Errorf("%w (dlq: %v)", publishErr, dlqErr)
 	}
 
-	return tx.Commit(ctx)
+	_, err := tx.Exec(ctx, `
+		UPDATE transaction_outbox
+		SET retry_count = $2, last_error = $3, next_retry_at = $4, updated_at = NOW()
+		WHERE id = $1
+	`, e.ID, retryCount, publishErr.Error(), time.Now().Add(r.backoff(retryCount)))
+	return err
+}
+
+// backoff returns the delay before the given attempt: exponential in the retry
+// count, capped at maxBackoff, with the upper half jittered so events that
+// failed together do not all retry together.
+func (r *Relay) backoff(retryCount int) time.Duration {
+	d := r.maxBackoff
+	if shift := retryCount - 1; shift < 32 {
+		if exp := r.baseBackoff << shift; exp > 0 && exp < r.maxBackoff {
+			d = exp
+		}
+	}
+	half := d / 2
+	return half + rand.N(half+1)
 }
 
 func (r *Relay) getTopicForEvent(eventType string) string {
@@ -129,6 +347,14 @@ func (r *Relay) getTopicForEvent(eventType string) string {
 		return kafka.TopicWebhookPending
 	case kafka.EventLedgerEntryCreated:
 		return kafka.TopicBalanceUpdate
+	case kafka.EventPayoutRequested:
+		return kafka.TopicPayoutPending
+	case kafka.EventPayoutStatusUpdated:
+		return kafka.TopicPayoutStatusUpdate
+	case kafka.EventDiscrepancyDetected:
+		return kafka.TopicDiscrepancyDetected
+	case kafka.EventReconciliationRequested:
+		return kafka.TopicReconciliationJob
 	default:
 		return kafka.TopicDLQ // Send unknown events to DLQ
 	}
@@ -0,0 +1,36 @@
+package outbox
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	r := &Relay{baseBackoff: time.Second, maxBackoff: 5 * time.Minute}
+
+	tests := []struct {
+		retryCount int
+		want       time.Duration
+	}{
+		{retryCount: 1, want: time.Second},
+		{retryCount: 2, want: 2 * time.Second},
+		{retryCount: 5, want: 16 * time.Second},
+		{retryCount: 9, want: 256 * time.Second},
+		{retryCount: 10, want: 5 * time.Minute},
+		{retryCount: 32, want: 5 * time.Minute},
+		{retryCount: 33, want: 5 * time.Minute},
+		{retryCount: 1000, want: 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.retryCount), func(t *testing.T) {
+			for range 100 {
+				got := r.backoff(tt.retryCount)
+				if got < tt.want/2 || got > tt.want {
+					t.Fatalf("backoff(%d) = %v, want within [%v, %v]", tt.retryCount, got, tt.want/2, tt.want)
+				}
+			}
+		})
+	}
+}
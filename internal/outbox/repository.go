@@ -0,0 +1,126 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Niiaks/Aegis/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrEventNotFound = errors.New("outbox event not found")
+
+type ListFilter struct {
+	Status        string `validate:"omitempty,oneof=pending processed failed dlq"`
+	EventType     string
+	CorrelationID string `validate:"omitempty,uuid"`
+	BeforeID      int64  `validate:"gte=0"`
+	Limit         int    `validate:"gte=1,lte=100"`
+}
+
+type ResetRequest struct {
+	IDs []int64 `json:"ids" validate:"required,min=1,max=500,dive,gt=0"`
+}
+
+type OutboxRepository interface {
+	ListEvents(ctx context.Context, filter *ListFilter) ([]model.TransactionOutbox, error)
+	GetEvent(ctx context.Context, id int64) (*model.TransactionOutbox, error)
+	ResetEvents(ctx context.Context, ids []int64) ([]int64, error)
+}
+
+type OutboxRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewOutboxRepository(db *pgxpool.Pool) *OutboxRepo {
+	return &OutboxRepo{db: db}
+}
+
+const eventColumns = `id, event_type, payload, partition_key, status, correlation_id, retry_count,
+	COALESCE(last_error, ''), next_retry_at, max_retries, created_at, updated_at`
+
+func scanEvent(row pgx.Row, e *model.TransactionOutbox) error {
+	return row.Scan(&e.ID, &e.EventType, &e.Payload, &e.PartitionKey, &e.Status, &e.CorrelationID, &e.RetryCount,
+		&e.LastError, &e.NextRetryAt, &e.MaxRetries, &e.CreatedAt, &e.UpdatedAt)
+}
+
+// ListEvents returns outbox rows newest first. Pages continue from BeforeID.
+func (or *OutboxRepo) ListEvents(ctx context.Context, filter *ListFilter) ([]model.TransactionOutbox, error) {
+	query := `SELECT ` + eventColumns + ` FROM transaction_outbox WHERE 1=1`
+	args := []any{}
+	addCondition := func(condition string, value any) {
+		args = append(args, value)
+		query += " AND " + fmt.Sprintf(condition, len(args))
+	}
+
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.EventType != "" {
+		addCondition("event_type = $%d", filter.EventType)
+	}
+	if filter.CorrelationID != "" {
+		addCondition("correlation_id = $%d", filter.CorrelationID)
+	}
+	if filter.BeforeID > 0 {
+		addCondition("id < $%d", filter.BeforeID)
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := or.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []model.TransactionOutbox{}
+	for rows.Next() {
+		var e model.TransactionOutbox
+		if err := scanEvent(rows, &e); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (or *OutboxRepo) GetEvent(ctx context.Context, id int64) (*model.TransactionOutbox, error) {
+	var e model.TransactionOutbox
+	err := scanEvent(or.db.QueryRow(ctx, `SELECT `+eventColumns+` FROM transaction_outbox WHERE id = $1`, id), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ResetEvents puts dlq and failed rows back to pending with a fresh retry
+// budget so the relay publishes them again. last_error is kept for reference.
+// Processed and pending rows are left alone; the IDs actually reset are returned.
+func (or *OutboxRepo) ResetEvents(ctx context.Context, ids []int64) ([]int64, error) {
+	rows, err := or.db.Query(ctx, `
+		UPDATE transaction_outbox
+		SET status = 'pending', retry_count = 0, next_retry_at = NULL, updated_at = NOW()
+		WHERE id = ANY($1) AND status IN ('dlq', 'failed')
+		RETURNING id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reset := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		reset = append(reset, id)
+	}
+	return reset, rows.Err()
+}
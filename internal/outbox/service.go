@@ -0,0 +1,88 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/Niiaks/Aegis/internal/kafka"
+	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/internal/model"
+)
+
+type EventPage struct {
+	Data       []model.TransactionOutbox `json:"data"`
+	NextCursor int64                     `json:"next_cursor,omitempty"`
+}
+
+type ResetResult struct {
+	Reset   []int64 `json:"reset"`
+	Skipped []int64 `json:"skipped"`
+}
+
+type OutboxService struct {
+	repo     OutboxRepository
+	replayer *kafka.DLQReplayer
+}
+
+func NewOutboxService(repo OutboxRepository, replayer *kafka.DLQReplayer) *OutboxService {
+	return &OutboxService{
+		repo:     repo,
+		replayer: replayer,
+	}
+}
+
+func (s *OutboxService) ListEvents(ctx context.Context, filter *ListFilter) (*EventPage, error) {
+	// Fetch one extra row to know whether another page exists.
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	events, err := s.repo.ListEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &EventPage{Data: events}
+	if len(events) > limit {
+		page.Data = events[:limit]
+		page.NextCursor = page.Data[limit-1].ID
+	}
+	return page, nil
+}
+
+func (s *OutboxService) GetEvent(ctx context.Context, id int64) (*model.TransactionOutbox, error) {
+	return s.repo.GetEvent(ctx, id)
+}
+
+// ResetEvents requeues dlq and failed rows. IDs that do not exist or are not
+// in a resettable state are reported as skipped.
+func (s *OutboxService) ResetEvents(ctx context.Context, ids []int64) (*ResetResult, error) {
+	logger := middleware.GetLogger(ctx)
+
+	reset, err := s.repo.ResetEvents(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[int64]bool, len(reset))
+	for _, id := range reset {
+		done[id] = true
+	}
+	result := &ResetResult{Reset: reset, Skipped: []int64{}}
+	for _, id := range ids {
+		if !done[id] {
+			result.Skipped = append(result.Skipped, id)
+		}
+	}
+
+	logger.Info().Ints64("reset", result.Reset).Ints64("skipped", result.Skipped).Msg("Reset outbox events to pending")
+	return result, nil
+}
+
+func (s *OutboxService) ReplayDLQ(ctx context.Context, opts kafka.ReplayOptions) (*kafka.ReplayResult, error) {
+	logger := middleware.GetLogger(ctx)
+
+	result, err := s.replayer.Replay(ctx, opts)
+	if result != nil {
+		logger.Info().Int("replayed", len(result.Replayed)).Bool("dry_run", result.DryRun).Msg("Replayed DLQ messages")
+	}
+	return result, err
+}
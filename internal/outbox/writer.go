@@ -0,0 +1,51 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Niiaks/Aegis/internal/events"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Execer is satisfied by pgx.Tx and *pgxpool.Pool. Pass the transaction that
+// makes the business change so the event commits with it.
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// Event is an event to queue for the relay.
+type Event struct {
+	Type   string
+	Source string
+	// PartitionKey orders events: those sharing a key are published in order.
+	PartitionKey string
+	// CorrelationID is usually the request ID. A missing or non-UUID value is
+	// replaced with a fresh UUID, as the column requires one.
+	CorrelationID string
+	Data          any
+}
+
+// Write wraps e.Data in a versioned envelope and inserts it into
+// transaction_outbox. Every outbox insert goes through here.
+func Write(ctx context.Context, db Execer, e Event) error {
+	correlationID := e.CorrelationID
+	if _, err := uuid.Parse(correlationID); err != nil {
+		correlationID = uuid.New().String()
+	}
+
+	env, err := events.New(e.Type, e.Source, correlationID, e.Data)
+	if err != nil {
+		return fmt.Errorf("failed to build %s event: %w", e.Type, err)
+	}
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", e.Type, err)
+	}
+
+	_, err = db.Exec(ctx, `INSERT INTO transaction_outbox (event_type,payload, partition_key,status, correlation_id) VALUES ($1, $2, $3, $4, $5)`,
+		e.Type, payload, e.PartitionKey, "pending", correlationID)
+	return err
+}
@@ -0,0 +1,276 @@
+package payout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/kafka"
+	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/internal/outbox"
+	"github.com/Niiaks/Aegis/internal/redis"
+	"github.com/Niiaks/Aegis/internal/wallet"
+	"github.com/Niiaks/Aegis/pkg/constants"
+	"github.com/Niiaks/Aegis/pkg/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// Final transfer statuses reported by Paystack. Any other status (pending,
+// otp, received, queued) means the transfer is still in flight.
+const (
+	TransferSuccess  = "success"
+	TransferFailed   = "failed"
+	TransferReversed = "reversed"
+)
+
+// ErrNotFound means no payout transaction exists for the reference.
+var ErrNotFound = errors.New("payout not found")
+
+// Settler moves payouts out of pending. Paystack transfers are asynchronous:
+// creating one only queues it, so a payout keeps its reservation until the
+// transfer webhook or a verify call reports how the transfer ended.
+type Settler struct {
+	db     *pgxpool.Pool
+	redis  *redis.Client
+	log    *zerolog.Logger
+	source string
+}
+
+// NewSettler builds a settler. source is the CloudEvents source of the payout
+// status events it publishes.
+func NewSettler(db *pgxpool.Pool, redis *redis.Client, log *zerolog.Logger, source string) *Settler {
+	return &Settler{
+		db:     db,
+		redis:  redis,
+		log:    log,
+		source: source,
+	}
+}
+
+// payout is a payout transaction and the settlement wallet it reserved funds on.
+type payout struct {
+	TransactionID string
+	UserID        string
+	WalletID      string
+	Amount        int64
+	Status        string
+	TransferCode  string
+}
+
+func (s *Settler) load(ctx context.Context, transactionID string) (*payout, error) {
+	p := payout{TransactionID: transactionID}
+	err := s.db.QueryRow(ctx, `
+		SELECT t.user_id, w.id, t.amount, t.status, COALESCE(t.psp_reference, '')
+		FROM transactions t
+		JOIN wallets w ON w.user_id = t.user_id AND w.currency = t.currency AND w.type = 'settlement'
+		WHERE t.id = $1 AND t.type = 'payout'`, transactionID,
+	).Scan(&p.UserID, &p.WalletID, &p.Amount, &p.Status, &p.TransferCode)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Apply settles the payout according to the status Paystack reports for its
+// transfer: success completes it, failed or reversed releases its funds, and
+// anything else records the transfer code and leaves it pending.
+func (s *Settler) Apply(ctx context.Context, transactionID, transferCode, status string) error {
+	switch status {
+	case TransferSuccess:
+		return s.Complete(ctx, transactionID, transferCode)
+	case TransferFailed, TransferReversed:
+		return s.Fail(ctx, transactionID, "transfer "+status)
+	default:
+		return s.Initiated(ctx, transactionID, transferCode)
+	}
+}
+
+// Initiated records the transfer code of a transfer Paystack has accepted.
+// The payout stays pending with its funds reserved.
+func (s *Settler) Initiated(ctx context.Context, transactionID, transferCode string) error {
+	_, err := s.db.Exec(ctx, `UPDATE transactions SET psp_reference = $1, updated_at = NOW() WHERE id = $2 AND type = 'payout' AND status = 'pending'`,
+		transferCode, transactionID)
+	if err != nil {
+		s.log.Error().Err(err).Str("transaction_id", transactionID).Msg("Transaction: Failed to record transfer code")
+		return err
+	}
+	s.log.Info().Str("transaction_id", transactionID).Str("transfer_code", transferCode).Msg("Transfer initiated, payout pending")
+	return nil
+}
+
+// Complete settles the reserved funds and posts the double-entry lines: the
+// seller wallet is debited and the external account is credited.
+func (s *Settler) Complete(ctx context.Context, transactionID, transferCode string) error {
+	p, err := s.load(ctx, transactionID)
+	if err != nil {
+		s.log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to load payout")
+		return err
+	}
+	if p.Status != "pending" {
+		s.log.Info().Str("transaction_id", transactionID).Str("status", p.Status).Msg("Payout already settled, skipping")
+		return nil
+	}
+
+	lock, err := wallet.Lock(ctx, s.db, s.redis, p.UserID)
+	if err != nil {
+		s.log.Error().Err(err).Str("user_id", p.UserID).Msg("Failed to acquire wallet lock")
+		return err
+	}
+	defer lock.Release(ctx)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.log.Error().Err(err).Msg("Failed to begin transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	res, err := tx.Exec(ctx, `UPDATE transactions SET psp_reference = $1, status = 'completed', updated_at = NOW() WHERE id = $2 AND status = 'pending'`,
+		transferCode, transactionID)
+	if err != nil {
+		s.log.Error().Err(err).Msg("Transaction: Failed to mark payout as completed")
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		s.log.Info().Str("transaction_id", transactionID).Msg("Payout already settled, skipping")
+		return nil
+	}
+
+	var sellerBalanceAfter int64
+	err = tx.QueryRow(ctx, "UPDATE wallets SET locked_balance = locked_balance - $1, fence_token = $3, updated_at = NOW() WHERE id = $2 AND locked_balance >= $1 RETURNING locked_balance", p.Amount, p.WalletID, lock.Token()).Scan(&sellerBalanceAfter)
+	if err != nil {
+		err = wallet.FenceError(err)
+		s.log.Error().Err(err).Msg("Wallet: Failed to settle seller reserved funds")
+		return err
+	}
+
+	var externalBalanceAfter int64
+	err = tx.QueryRow(ctx, "UPDATE wallets SET balance = balance - $1, updated_at = NOW() WHERE id = $2 RETURNING balance", p.Amount, constants.AccountExternalID).Scan(&externalBalanceAfter)
+	if err != nil {
+		s.log.Error().Err(err).Msg("Wallet: Failed to update external wallet")
+		return err
+	}
+
+	// Debit seller for the amount paid out
+	_, err = tx.Exec(ctx, "INSERT INTO ledger_entries (transaction_id,account_id,debit,credit,balance_after,description,updated_at,created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)", transactionID, p.WalletID, p.Amount, 0, sellerBalanceAfter, "payout", time.Now(), time.Now())
+	if err != nil {
+		s.log.Error().Err(err).Msg("Ledger: Failed to insert seller ledger entry")
+		return err
+	}
+
+	// Credit external account (money leaving the system)
+	_, err = tx.Exec(ctx, "INSERT INTO ledger_entries (transaction_id,account_id,debit,credit,balance_after,description,updated_at,created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)", transactionID, constants.AccountExternalID, 0, p.Amount, externalBalanceAfter, "payout", time.Now(), time.Now())
+	if err != nil {
+		s.log.Error().Err(err).Msg("Ledger: Failed to insert external ledger entry")
+		return err
+	}
+
+	if err := s.insertStatusEvent(ctx, tx, types.PayoutStatusEvent{
+		TransactionID: transactionID,
+		UserID:        p.UserID,
+		Status:        "completed",
+		TransferCode:  transferCode,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	s.log.Info().Str("transaction_id", transactionID).Str("transfer_code", transferCode).Msg("Payout completed")
+	return nil
+}
+
+// Fail marks the payout as failed and returns the reserved funds to the
+// seller's available balance. No ledger lines are posted since no money moved.
+// Only call it once Paystack has confirmed the transfer did not go through.
+func (s *Settler) Fail(ctx context.Context, transactionID, reason string) error {
+	p, err := s.load(ctx, transactionID)
+	if err != nil {
+		s.log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to load payout")
+		return err
+	}
+	if p.Status != "pending" {
+		s.log.Info().Str("transaction_id", transactionID).Str("status", p.Status).Msg("Payout already settled, skipping")
+		return nil
+	}
+
+	lock, err := wallet.Lock(ctx, s.db, s.redis, p.UserID)
+	if err != nil {
+		s.log.Error().Err(err).Str("user_id", p.UserID).Msg("Failed to acquire wallet lock")
+		return err
+	}
+	defer lock.Release(ctx)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.log.Error().Err(err).Msg("Failed to begin transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	res, err := tx.Exec(ctx, `UPDATE transactions SET status = 'failed', failure_reason = $1, updated_at = NOW() WHERE id = $2 AND status = 'pending'`,
+		reason, transactionID)
+	if err != nil {
+		s.log.Error().Err(err).Msg("Transaction: Failed to mark payout as failed")
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		s.log.Info().Str("transaction_id", transactionID).Msg("Payout already settled, skipping")
+		return nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE wallets
+		SET locked_balance = locked_balance - $1,
+			balance = balance + $1,
+			fence_token = $3,
+			updated_at = NOW()
+		WHERE id = $2 AND locked_balance >= $1`,
+		p.Amount, p.WalletID, lock.Token())
+	if err != nil {
+		err = wallet.FenceError(err)
+		s.log.Error().Err(err).Msg("Wallet: Failed to release reserved funds")
+		return err
+	}
+
+	if err := s.insertStatusEvent(ctx, tx, types.PayoutStatusEvent{
+		TransactionID: transactionID,
+		UserID:        p.UserID,
+		Status:        "failed",
+		FailureReason: reason,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	s.log.Info().Str("transaction_id", transactionID).Str("reason", reason).Msg("Payout failed, reserved funds released")
+	return nil
+}
+
+func (s *Settler) insertStatusEvent(ctx context.Context, tx pgx.Tx, event types.PayoutStatusEvent) error {
+	requestID := middleware.GetRequestIDFromContext(ctx)
+	if requestID == "" {
+		s.log.Warn().Msg("Request ID missing in context, outbox will generate a correlation ID")
+	}
+
+	err := outbox.Write(ctx, tx, outbox.Event{
+		Type:          kafka.EventPayoutStatusUpdated,
+		Source:        s.source,
+		PartitionKey:  event.UserID,
+		CorrelationID: requestID,
+		Data:          event,
+	})
+	if err != nil {
+		s.log.Error().Err(err).Msg("Outbox: Failed to insert payout status event")
+		return err
+	}
+	return nil
+}
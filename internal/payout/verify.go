@@ -0,0 +1,113 @@
+package payout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/config"
+	"github.com/Niiaks/Aegis/internal/psp"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+const verifyBatchSize = 100
+
+// Verifier settles payouts whose transfer webhook never arrived by asking
+// Paystack for the transfer's status.
+type Verifier struct {
+	db       *pgxpool.Pool
+	settler  *Settler
+	paystack psp.Client
+	logger   *zerolog.Logger
+	cfg      config.PayoutConfig
+}
+
+func NewVerifier(db *pgxpool.Pool, settler *Settler, paystack psp.Client, logger *zerolog.Logger, cfg config.PayoutConfig) *Verifier {
+	return &Verifier{
+		db:       db,
+		settler:  settler,
+		paystack: paystack,
+		logger:   logger,
+		cfg:      cfg,
+	}
+}
+
+// Start verifies every configured interval until ctx is cancelled.
+func (v *Verifier) Start(ctx context.Context) error {
+	v.logger.Info().Msg("Starting Payout Verifier")
+	ticker := time.NewTicker(v.cfg.VerifyInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			v.logger.Info().Msg("Stopping Payout Verifier")
+			return nil
+		case <-ticker.C:
+		}
+
+		if _, err := v.Run(ctx); err != nil && ctx.Err() == nil {
+			v.logger.Error().Err(err).Msg("Payout verification failed")
+		}
+	}
+}
+
+// Run verifies payouts whose transfer was initiated more than VerifyAfter ago
+// and is still pending, and settles those Paystack has finished. Payouts
+// without a transfer code are left to the payout worker, which verifies the
+// reference itself before creating a transfer.
+func (v *Verifier) Run(ctx context.Context) (int, error) {
+	rows, err := v.db.Query(ctx, `
+		SELECT id::text FROM transactions
+		WHERE type = 'payout' AND status = 'pending' AND psp_reference IS NOT NULL AND updated_at < $1
+		ORDER BY updated_at
+		LIMIT $2`,
+		time.Now().Add(-v.cfg.VerifyAfter), verifyBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		transfer, err := v.paystack.VerifyTransfer(ctx, id)
+		if errors.Is(err, psp.ErrNotFound) {
+			v.logger.Error().Str("transaction_id", id).Msg("Payout has a transfer code but Paystack has no transfer for it")
+			continue
+		}
+		if err != nil {
+			v.logger.Warn().Err(err).Str("transaction_id", id).Msg("Failed to verify transfer")
+			continue
+		}
+		switch transfer.Data.Status {
+		case TransferSuccess, TransferFailed, TransferReversed:
+		default:
+			// Still in flight; check again next run.
+			continue
+		}
+		if err := v.settler.Apply(ctx, id, transfer.Data.TransferCode, transfer.Data.Status); err != nil {
+			v.logger.Error().Err(err).Str("transaction_id", id).Msg("Failed to settle verified payout")
+			continue
+		}
+		settled++
+	}
+
+	v.logger.Info().Int("checked", len(ids)).Int("settled", settled).Msg("Payout verification complete")
+	return settled, ctx.Err()
+}
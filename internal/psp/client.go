@@ -4,15 +4,26 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/Niiaks/Aegis/pkg/types"
 	"github.com/rs/zerolog/log"
 )
 
+// ErrRequestRejected is returned when Paystack explicitly rejects a request.
+// Retrying the same request will not succeed.
+var ErrRequestRejected = errors.New("paystack rejected request")
+
+// ErrNotFound is returned when Paystack has no record of the requested
+// resource (HTTP 404). It wraps ErrRequestRejected.
+var ErrNotFound = errors.New("paystack resource not found")
+
 type PaystackClient struct {
 	httpClient *http.Client
 	secretKey  string
@@ -20,8 +31,14 @@ type PaystackClient struct {
 }
 
 type Client interface {
-	InitializePayment(ctx context.Context)
-	CreateTransfer(ctx context.Context)
+	InitializePayment(ctx context.Context, req *types.InitializePaymentRequest, transactionID string) (*types.InitializePaymentResponse, error)
+	CreateTransfer(ctx context.Context, req *types.TransferRequest) (*types.TransferResponse, error)
+	VerifyTransfer(ctx context.Context, reference string) (*types.TransferResponse, error)
+	CreateRefund(ctx context.Context, req *types.PaystackRefundRequest) (*types.PaystackRefundResponse, error)
+	FetchRefund(ctx context.Context, id string) (*types.PaystackRefundResponse, error)
+	ListRefunds(ctx context.Context, transaction string) (*types.PaystackRefundListResponse, error)
+	ListTransactions(ctx context.Context, from, to time.Time, page int) (*types.PaystackTransactionListResponse, error)
+	VerifyTransaction(ctx context.Context, reference string) (*types.PaystackVerifyResponse, error)
 }
 
 func NewPaystackClient(secretKey, baseURL string) *PaystackClient {
@@ -62,8 +79,157 @@ func (c *PaystackClient) InitializePayment(ctx context.Context, req *types.Initi
 	return &resp, nil
 }
 
+// CreateTransfer initiates a transfer from the Paystack balance to a transfer recipient.
+// The reference must be unique per payout so Paystack can deduplicate retries.
+func (c *PaystackClient) CreateTransfer(ctx context.Context, req *types.TransferRequest) (*types.TransferResponse, error) {
+	if req.Source == "" {
+		req.Source = "balance"
+	}
+	respBody, err := c.doRequest(ctx, http.MethodPost, "/transfer", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp types.TransferResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !resp.Status {
+		return nil, fmt.Errorf("%w: %s", ErrRequestRejected, resp.Message)
+	}
+
+	return &resp, nil
+}
+
+// VerifyTransfer fetches a transfer by the reference it was created with. It
+// returns ErrNotFound if Paystack never received a transfer with that reference.
+func (c *PaystackClient) VerifyTransfer(ctx context.Context, reference string) (*types.TransferResponse, error) {
+	respBody, err := c.doRequest(ctx, http.MethodGet, "/transfer/verify/"+url.PathEscape(reference), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp types.TransferResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !resp.Status {
+		return nil, fmt.Errorf("%w: %s", ErrRequestRejected, resp.Message)
+	}
+
+	return &resp, nil
+}
+
+// CreateRefund refunds all or part of a completed charge identified by its Paystack reference.
+func (c *PaystackClient) CreateRefund(ctx context.Context, req *types.PaystackRefundRequest) (*types.PaystackRefundResponse, error) {
+	respBody, err := c.doRequest(ctx, http.MethodPost, "/refund", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp types.PaystackRefundResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !resp.Status {
+		return nil, fmt.Errorf("%w: %s", ErrRequestRejected, resp.Message)
+	}
+
+	return &resp, nil
+}
+
+// FetchRefund fetches the current state of a refund by its Paystack ID.
+func (c *PaystackClient) FetchRefund(ctx context.Context, id string) (*types.PaystackRefundResponse, error) {
+	respBody, err := c.doRequest(ctx, http.MethodGet, "/refund/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp types.PaystackRefundResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !resp.Status {
+		return nil, fmt.Errorf("%w: %s", ErrRequestRejected, resp.Message)
+	}
+
+	return &resp, nil
+}
+
+// ListRefunds returns the refunds made against a charge, identified by its
+// Paystack reference.
+func (c *PaystackClient) ListRefunds(ctx context.Context, transaction string) (*types.PaystackRefundListResponse, error) {
+	query := url.Values{}
+	query.Set("transaction", transaction)
+	query.Set("perPage", "100")
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, "/refund?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp types.PaystackRefundListResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !resp.Status {
+		return nil, fmt.Errorf("%w: %s", ErrRequestRejected, resp.Message)
+	}
+
+	return &resp, nil
+}
+
+// ListTransactions returns one page of charges created between from and to.
+func (c *PaystackClient) ListTransactions(ctx context.Context, from, to time.Time, page int) (*types.PaystackTransactionListResponse, error) {
+	query := url.Values{}
+	query.Set("from", from.UTC().Format(time.RFC3339))
+	query.Set("to", to.UTC().Format(time.RFC3339))
+	query.Set("perPage", "100")
+	query.Set("page", strconv.Itoa(page))
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, "/transaction?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp types.PaystackTransactionListResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !resp.Status {
+		return nil, fmt.Errorf("%w: %s", ErrRequestRejected, resp.Message)
+	}
+
+	return &resp, nil
+}
+
+// VerifyTransaction fetches the current state of a single charge by reference.
+func (c *PaystackClient) VerifyTransaction(ctx context.Context, reference string) (*types.PaystackVerifyResponse, error) {
+	respBody, err := c.doRequest(ctx, http.MethodGet, "/transaction/verify/"+url.PathEscape(reference), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp types.PaystackVerifyResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !resp.Status {
+		return nil, fmt.Errorf("%w: %s", ErrRequestRejected, resp.Message)
+	}
+
+	return &resp, nil
+}
+
 func (c *PaystackClient) doRequest(ctx context.Context, method, path string, body any) ([]byte, error) {
-	url := c.baseURL + path
+	endpoint := c.baseURL + path
 
 	var reqBody io.Reader
 	if body != nil {
@@ -75,7 +241,7 @@ func (c *PaystackClient) doRequest(ctx context.Context, method, path string, bod
 		reqBody = bytes.NewReader(jsonBytes)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create HTTP request")
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -90,7 +256,7 @@ func (c *PaystackClient) doRequest(ctx context.Context, method, path string, bod
 	if err != nil {
 		log.Error().Err(err).
 			Str("method", method).
-			Str("url", url).
+			Str("url", endpoint).
 			Int64("duration_ms", duration).
 			Msg("HTTP request failed")
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -101,7 +267,7 @@ func (c *PaystackClient) doRequest(ctx context.Context, method, path string, bod
 	if err != nil {
 		log.Error().Err(err).
 			Str("method", method).
-			Str("url", url).
+			Str("url", endpoint).
 			Int64("duration_ms", duration).
 			Msg("Failed to read response body")
 		return nil, fmt.Errorf("failed to read response: %w", err)
@@ -111,17 +277,23 @@ func (c *PaystackClient) doRequest(ctx context.Context, method, path string, bod
 		log.Error().
 			Int("status", resp.StatusCode).
 			Str("method", method).
-			Str("url", url).
+			Str("url", endpoint).
 			Int64("duration_ms", duration).
 			Str("body", string(respBody)).
 			Msg("Paystack API error response")
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %w: body=%s", ErrRequestRejected, ErrNotFound, string(respBody))
+		}
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return nil, fmt.Errorf("%w: status=%d body=%s", ErrRequestRejected, resp.StatusCode, string(respBody))
+		}
 		return nil, fmt.Errorf("paystack error: status=%d body=%s", resp.StatusCode, string(respBody))
 	}
 
 	log.Info().
 		Int("status", resp.StatusCode).
 		Str("method", method).
-		Str("url", url).
+		Str("url", endpoint).
 		Int64("duration_ms", duration).
 		Msg("Paystack API request successful")
 
@@ -0,0 +1,178 @@
+package reconciliation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/pkg/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = validator.New()
+
+type ReconciliationHandler struct {
+	service *ReconciliationService
+}
+
+func NewReconciliationHandler(service *ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		service: service,
+	}
+}
+
+func (rh *ReconciliationHandler) ListDiscrepancies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	q := r.URL.Query()
+	filter := DiscrepancyFilter{
+		Status:     q.Get("status"),
+		ReasonCode: q.Get("reason_code"),
+		RunID:      q.Get("run_id"),
+		AssignedTo: q.Get("assigned_to"),
+		Limit:      50,
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := types.DecodeCursor(v)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		filter.Cursor = cursor
+	}
+
+	if err := validate.Struct(&filter); err != nil {
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := rh.service.ListDiscrepancies(ctx, &filter)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list discrepancies")
+		http.Error(w, "Failed to list discrepancies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (rh *ReconciliationHandler) GetDiscrepancy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	id := chi.URLParam(r, "id")
+	if err := validate.Var(id, "required,uuid"); err != nil {
+		http.Error(w, "Invalid discrepancy id", http.StatusBadRequest)
+		return
+	}
+
+	d, err := rh.service.GetDiscrepancy(ctx, id)
+	switch {
+	case errors.Is(err, ErrDiscrepancyNotFound):
+		http.Error(w, "Discrepancy not found", http.StatusNotFound)
+		return
+	case err != nil:
+		logger.Error().Err(err).Str("discrepancy_id", id).Msg("Failed to get discrepancy")
+		http.Error(w, "Failed to get discrepancy", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+func (rh *ReconciliationHandler) AssignDiscrepancy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	id := chi.URLParam(r, "id")
+	if err := validate.Var(id, "required,uuid"); err != nil {
+		http.Error(w, "Invalid discrepancy id", http.StatusBadRequest)
+		return
+	}
+
+	var req AssignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode assign request")
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := validate.Struct(&req); err != nil {
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d, err := rh.service.AssignDiscrepancy(ctx, id, &req)
+	switch {
+	case errors.Is(err, ErrDiscrepancyNotFound):
+		http.Error(w, "Discrepancy not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrAlreadyResolved):
+		http.Error(w, ErrAlreadyResolved.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		logger.Error().Err(err).Str("discrepancy_id", id).Msg("Failed to assign discrepancy")
+		http.Error(w, "Failed to assign discrepancy", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+func (rh *ReconciliationHandler) ResolveDiscrepancy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	id := chi.URLParam(r, "id")
+	if err := validate.Var(id, "required,uuid"); err != nil {
+		http.Error(w, "Invalid discrepancy id", http.StatusBadRequest)
+		return
+	}
+
+	var req ResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode resolve request")
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := validate.Struct(&req); err != nil {
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d, err := rh.service.ResolveDiscrepancy(ctx, id, &req)
+	switch {
+	case errors.Is(err, ErrDiscrepancyNotFound):
+		http.Error(w, "Discrepancy not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrAlreadyResolved):
+		http.Error(w, ErrAlreadyResolved.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrAccountNotFound), errors.Is(err, ErrCurrencyMismatch), errors.Is(err, ErrInsufficientBalance):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		logger.Error().Err(err).Str("discrepancy_id", id).Msg("Failed to resolve discrepancy")
+		http.Error(w, "Failed to resolve discrepancy", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
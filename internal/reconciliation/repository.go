@@ -0,0 +1,522 @@
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/events"
+	"github.com/Niiaks/Aegis/internal/kafka"
+	"github.com/Niiaks/Aegis/internal/model"
+	"github.com/Niiaks/Aegis/internal/outbox"
+	"github.com/Niiaks/Aegis/pkg/constants"
+	"github.com/Niiaks/Aegis/pkg/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrDiscrepancyNotFound = errors.New("discrepancy not found")
+	ErrAlreadyResolved     = errors.New("discrepancy already resolved")
+	ErrAccountNotFound     = errors.New("adjustment account not found")
+	ErrCurrencyMismatch    = errors.New("adjustment accounts hold different currencies")
+	ErrInsufficientBalance = errors.New("adjustment would overdraw account")
+	ErrSettlementDate      = errors.New("settlement file is for a different date")
+)
+
+// Reason codes recorded on discrepancies, one per kind of mismatch.
+const (
+	ReasonMissingAtPSP         = "missing_at_psp"
+	ReasonMissingInAegis       = "missing_in_aegis"
+	ReasonAmountMismatch       = "amount_mismatch"
+	ReasonCurrencyMismatch     = "currency_mismatch"
+	ReasonStatusMismatch       = "status_mismatch"
+	ReasonLedgerImbalance      = "ledger_imbalance"
+	ReasonLedgerAmountMismatch = "ledger_amount_mismatch"
+	ReasonFeeMismatch          = "fee_mismatch"
+	ReasonNetMismatch          = "net_mismatch"
+	ReasonDuplicateSettlement  = "duplicate_settlement"
+)
+
+// Charge is a payment as Aegis recorded it.
+type Charge struct {
+	ID           uuid.UUID
+	PspReference string
+	Amount       int64
+	Currency     string
+	Status       string
+}
+
+// LedgerTotal sums the ledger lines posted for one transaction.
+type LedgerTotal struct {
+	Debit         int64
+	Credit        int64
+	ExternalDebit int64
+}
+
+type DiscrepancyFilter struct {
+	Status     string `validate:"omitempty,oneof=unresolved resolved"`
+	ReasonCode string
+	RunID      string `validate:"omitempty,uuid"`
+	AssignedTo string
+	Cursor     *types.Cursor
+	Limit      int `validate:"gte=1,lte=100"`
+}
+
+type AssignRequest struct {
+	AssignedTo string `json:"assigned_to" validate:"required,max=255"`
+}
+
+// Adjustment moves Amount from one ledger account to another. Account IDs are
+// wallet IDs, including the system external and platform accounts.
+type Adjustment struct {
+	DebitAccountID  string `json:"debit_account_id" validate:"required,uuid"`
+	CreditAccountID string `json:"credit_account_id" validate:"required,uuid,nefield=DebitAccountID"`
+	Amount          int64  `json:"amount" validate:"required,gt=0"`
+}
+
+type ResolveRequest struct {
+	ResolutionType string      `json:"resolution_type" validate:"required,oneof=manual_adjustment psp_error timing_difference duplicate no_action"`
+	Note           string      `json:"note" validate:"required,max=2000"`
+	ResolvedBy     string      `json:"resolved_by" validate:"required,max=255"`
+	Adjustment     *Adjustment `json:"adjustment" validate:"required_if=ResolutionType manual_adjustment,excluded_unless=ResolutionType manual_adjustment"`
+}
+
+type ReconciliationRepository interface {
+	ListSettledCharges(ctx context.Context, from, to time.Time) ([]Charge, error)
+	FindChargesByReference(ctx context.Context, references []string) (map[string]Charge, error)
+	LedgerTotals(ctx context.Context, transactionIDs []uuid.UUID) (map[uuid.UUID]LedgerTotal, error)
+	WebhookFees(ctx context.Context, references []string) (map[string]int64, error)
+	SaveRun(ctx context.Context, run *model.ReconciliationRun, discrepancies []model.Discrepancy, correlationID string) error
+	QueueRun(ctx context.Context, day time.Time, correlationID string) error
+	ListDiscrepancies(ctx context.Context, filter *DiscrepancyFilter) ([]model.Discrepancy, error)
+	GetDiscrepancy(ctx context.Context, id string) (*model.Discrepancy, error)
+	AssignDiscrepancy(ctx context.Context, id, assignee string) (*model.Discrepancy, error)
+	ResolveDiscrepancy(ctx context.Context, id string, req *ResolveRequest) (*model.Discrepancy, error)
+}
+
+type ReconciliationRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewReconciliationRepository(db *pgxpool.Pool) *ReconciliationRepo {
+	return &ReconciliationRepo{db: db}
+}
+
+// ListSettledCharges returns the completed (or since refunded) payments created in [from, to).
+func (rr *ReconciliationRepo) ListSettledCharges(ctx context.Context, from, to time.Time) ([]Charge, error) {
+	rows, err := rr.db.Query(ctx, `
+		SELECT id, COALESCE(psp_reference, ''), amount, currency, status
+		FROM transactions
+		WHERE type = 'payment_intent'
+			AND status IN ('completed', 'refunded')
+			AND created_at >= $1 AND created_at < $2
+		ORDER BY created_at ASC`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var charges []Charge
+	for rows.Next() {
+		var c Charge
+		if err := rows.Scan(&c.ID, &c.PspReference, &c.Amount, &c.Currency, &c.Status); err != nil {
+			return nil, err
+		}
+		charges = append(charges, c)
+	}
+	return charges, rows.Err()
+}
+
+// FindChargesByReference looks payments up by PSP reference regardless of date.
+func (rr *ReconciliationRepo) FindChargesByReference(ctx context.Context, references []string) (map[string]Charge, error) {
+	rows, err := rr.db.Query(ctx, `
+		SELECT id, psp_reference, amount, currency, status
+		FROM transactions
+		WHERE type = 'payment_intent' AND psp_reference = ANY($1)`, references)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	charges := make(map[string]Charge, len(references))
+	for rows.Next() {
+		var c Charge
+		if err := rows.Scan(&c.ID, &c.PspReference, &c.Amount, &c.Currency, &c.Status); err != nil {
+			return nil, err
+		}
+		charges[c.PspReference] = c
+	}
+	return charges, rows.Err()
+}
+
+func (rr *ReconciliationRepo) LedgerTotals(ctx context.Context, transactionIDs []uuid.UUID) (map[uuid.UUID]LedgerTotal, error) {
+	rows, err := rr.db.Query(ctx, `
+		SELECT transaction_id,
+			SUM(debit),
+			SUM(credit),
+			COALESCE(SUM(debit) FILTER (WHERE account_id = $2), 0)
+		FROM ledger_entries
+		WHERE transaction_id = ANY($1)
+		GROUP BY transaction_id`, transactionIDs, constants.AccountExternalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[uuid.UUID]LedgerTotal, len(transactionIDs))
+	for rows.Next() {
+		var id uuid.UUID
+		var t LedgerTotal
+		if err := rows.Scan(&id, &t.Debit, &t.Credit, &t.ExternalDebit); err != nil {
+			return nil, err
+		}
+		totals[id] = t
+	}
+	return totals, rows.Err()
+}
+
+// WebhookFees returns the fees Paystack reported in the charge.success webhook
+// for each reference, as stored in psp_webhooks.
+func (rr *ReconciliationRepo) WebhookFees(ctx context.Context, references []string) (map[string]int64, error) {
+	rows, err := rr.db.Query(ctx, `
+		SELECT payload->'data'->>'reference', COALESCE((payload->'data'->>'fees')::BIGINT, 0)
+		FROM psp_webhooks
+		WHERE payload->'data'->>'reference' = ANY($1)`, references)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fees := make(map[string]int64, len(references))
+	for rows.Next() {
+		var ref string
+		var fee int64
+		if err := rows.Scan(&ref, &fee); err != nil {
+			return nil, err
+		}
+		fees[ref] = fee
+	}
+	return fees, rows.Err()
+}
+
+// SaveRun records the run and its discrepancies, and queues a discrepancy
+// detected event for each new one through the outbox. API runs are unique per
+// day: running a day again reuses its run, updates its status and only adds
+// discrepancies the run does not already hold, so alerts are not repeated.
+func (rr *ReconciliationRepo) SaveRun(ctx context.Context, run *model.ReconciliationRun, discrepancies []model.Discrepancy, correlationID string) error {
+	tx, err := rr.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO reconciliation_runs (run_date, status, source, source_file)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (run_date) WHERE source = 'api'
+		DO UPDATE SET status = EXCLUDED.status, updated_at = NOW()
+		RETURNING id, created_at, updated_at`, run.RunDate, run.Status, run.Source, run.SourceFile).
+		Scan(&run.ID, &run.CreatedAt, &run.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	for i := range discrepancies {
+		d := &discrepancies[i]
+		d.ReconciliationRunID = run.ID
+		var inserted bool
+		err = tx.QueryRow(ctx, `
+			WITH existing AS (
+				SELECT id, status, created_at, updated_at FROM discrepancies
+				WHERE reconciliation_run_id = $1
+					AND transaction_id IS NOT DISTINCT FROM $2
+					AND psp_reference IS NOT DISTINCT FROM NULLIF($3, '')
+					AND reason_code = $6
+				LIMIT 1
+			), inserted AS (
+				INSERT INTO discrepancies (reconciliation_run_id, transaction_id, psp_reference, expected_amount, actual_amount, reason_code, reason, status)
+				SELECT $1, $2, NULLIF($3, ''), $4::BIGINT, $5::BIGINT, $6, $7::TEXT, 'unresolved'
+				WHERE NOT EXISTS (SELECT 1 FROM existing)
+				RETURNING id, status, created_at, updated_at
+			)
+			SELECT id, status, created_at, updated_at, true FROM inserted
+			UNION ALL
+			SELECT id, status, created_at, updated_at, false FROM existing`,
+			d.ReconciliationRunID, d.TransactionID, d.PspReference, d.ExpectedAmount, d.ActualAmount, d.ReasonCode, d.Reason,
+		).Scan(&d.ID, &d.Status, &d.CreatedAt, &d.UpdatedAt, &inserted)
+		if err != nil {
+			return err
+		}
+		if !inserted {
+			continue
+		}
+
+		event := types.DiscrepancyDetectedEvent{
+			DiscrepancyID:       d.ID.String(),
+			ReconciliationRunID: run.ID.String(),
+			PspReference:        d.PspReference,
+			ReasonCode:          d.ReasonCode,
+			ExpectedAmount:      d.ExpectedAmount,
+			ActualAmount:        d.ActualAmount,
+		}
+		if d.TransactionID != nil {
+			event.TransactionID = d.TransactionID.String()
+		}
+		err = outbox.Write(ctx, tx, outbox.Event{
+			Type:          kafka.EventDiscrepancyDetected,
+			Source:        events.SourceReconciliation,
+			PartitionKey:  run.ID.String(),
+			CorrelationID: correlationID,
+			Data:          event,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// QueueRun writes a reconciliation job for day to the outbox, for the
+// reconciliation worker to run.
+func (rr *ReconciliationRepo) QueueRun(ctx context.Context, day time.Time, correlationID string) error {
+	runDate := day.UTC().Format(time.DateOnly)
+	return outbox.Write(ctx, rr.db, outbox.Event{
+		Type:          kafka.EventReconciliationRequested,
+		Source:        events.SourceReconciliation,
+		PartitionKey:  runDate,
+		CorrelationID: correlationID,
+		Data:          types.ReconciliationJob{RunDate: runDate},
+	})
+}
+
+const discrepancyColumns = `id, reconciliation_run_id, transaction_id, COALESCE(psp_reference, ''), expected_amount, actual_amount,
+	reason_code, reason, status, COALESCE(assigned_to, ''), COALESCE(resolution_type, ''), COALESCE(resolution_note, ''),
+	COALESCE(resolved_by, ''), resolved_at, adjustment_transaction_id, created_at, updated_at`
+
+func scanDiscrepancy(row pgx.Row, d *model.Discrepancy) error {
+	return row.Scan(&d.ID, &d.ReconciliationRunID, &d.TransactionID, &d.PspReference, &d.ExpectedAmount, &d.ActualAmount,
+		&d.ReasonCode, &d.Reason, &d.Status, &d.AssignedTo, &d.ResolutionType, &d.ResolutionNote,
+		&d.ResolvedBy, &d.ResolvedAt, &d.AdjustmentTxID, &d.CreatedAt, &d.UpdatedAt)
+}
+
+func (rr *ReconciliationRepo) ListDiscrepancies(ctx context.Context, filter *DiscrepancyFilter) ([]model.Discrepancy, error) {
+	query := `SELECT ` + discrepancyColumns + ` FROM discrepancies WHERE 1=1`
+	args := []any{}
+	addCondition := func(condition string, value any) {
+		args = append(args, value)
+		query += " AND " + fmt.Sprintf(condition, len(args))
+	}
+
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.ReasonCode != "" {
+		addCondition("reason_code = $%d", filter.ReasonCode)
+	}
+	if filter.RunID != "" {
+		addCondition("reconciliation_run_id = $%d", filter.RunID)
+	}
+	if filter.AssignedTo != "" {
+		addCondition("assigned_to = $%d", filter.AssignedTo)
+	}
+	if filter.Cursor != nil {
+		args = append(args, filter.Cursor.CreatedAt, filter.Cursor.ID)
+		query += fmt.Sprintf(" AND (created_at, id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := rr.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	discrepancies := []model.Discrepancy{}
+	for rows.Next() {
+		var d model.Discrepancy
+		if err := scanDiscrepancy(rows, &d); err != nil {
+			return nil, err
+		}
+		discrepancies = append(discrepancies, d)
+	}
+	return discrepancies, rows.Err()
+}
+
+func (rr *ReconciliationRepo) GetDiscrepancy(ctx context.Context, id string) (*model.Discrepancy, error) {
+	var d model.Discrepancy
+	err := scanDiscrepancy(rr.db.QueryRow(ctx, `SELECT `+discrepancyColumns+` FROM discrepancies WHERE id = $1`, id), &d)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDiscrepancyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (rr *ReconciliationRepo) AssignDiscrepancy(ctx context.Context, id, assignee string) (*model.Discrepancy, error) {
+	var d model.Discrepancy
+	err := scanDiscrepancy(rr.db.QueryRow(ctx, `
+		UPDATE discrepancies
+		SET assigned_to = $2, updated_at = NOW()
+		WHERE id = $1 AND status = 'unresolved'
+		RETURNING `+discrepancyColumns, id, assignee), &d)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Either it does not exist or it is already closed.
+		if _, err := rr.GetDiscrepancy(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrAlreadyResolved
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ResolveDiscrepancy closes a discrepancy. When the request carries an
+// adjustment, a completed adjustment transaction and its balancing ledger lines
+// are posted in the same database transaction.
+func (rr *ReconciliationRepo) ResolveDiscrepancy(ctx context.Context, id string, req *ResolveRequest) (*model.Discrepancy, error) {
+	tx, err := rr.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	var relatedTxID *uuid.UUID
+	err = tx.QueryRow(ctx, "SELECT status, transaction_id FROM discrepancies WHERE id = $1 FOR UPDATE", id).Scan(&status, &relatedTxID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDiscrepancyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status == "resolved" {
+		return nil, ErrAlreadyResolved
+	}
+
+	var adjustmentTxID *string
+	if req.Adjustment != nil {
+		txID, err := postAdjustment(ctx, tx, id, relatedTxID, req.Adjustment)
+		if err != nil {
+			return nil, err
+		}
+		adjustmentTxID = &txID
+	}
+
+	var d model.Discrepancy
+	err = scanDiscrepancy(tx.QueryRow(ctx, `
+		UPDATE discrepancies
+		SET status = 'resolved',
+			resolution_type = $2,
+			resolution_note = $3,
+			resolved_by = $4,
+			resolved_at = NOW(),
+			adjustment_transaction_id = $5,
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+discrepancyColumns, id, req.ResolutionType, req.Note, req.ResolvedBy, adjustmentTxID), &d)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, tx.Commit(ctx)
+}
+
+type adjustmentAccount struct {
+	id       string
+	currency string
+	balance  int64
+}
+
+// apply returns the account balance after posting the given debit and credit.
+// The external account is debit-normal; every other wallet is credit-normal.
+func (a adjustmentAccount) apply(debit, credit int64) int64 {
+	if a.id == string(constants.AccountExternalID) {
+		return a.balance + debit - credit
+	}
+	return a.balance - debit + credit
+}
+
+func postAdjustment(ctx context.Context, tx pgx.Tx, discrepancyID string, parentTxID *uuid.UUID, adj *Adjustment) (string, error) {
+	// Lock both wallets in id order so concurrent adjustments cannot deadlock.
+	rows, err := tx.Query(ctx, `
+		SELECT id, currency, balance
+		FROM wallets
+		WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE`, []string{adj.DebitAccountID, adj.CreditAccountID})
+	if err != nil {
+		return "", err
+	}
+	accounts := map[string]adjustmentAccount{}
+	for rows.Next() {
+		var a adjustmentAccount
+		if err := rows.Scan(&a.id, &a.currency, &a.balance); err != nil {
+			rows.Close()
+			return "", err
+		}
+		accounts[a.id] = a
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	debitAccount, ok := accounts[adj.DebitAccountID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrAccountNotFound, adj.DebitAccountID)
+	}
+	creditAccount, ok := accounts[adj.CreditAccountID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrAccountNotFound, adj.CreditAccountID)
+	}
+	if debitAccount.currency != creditAccount.currency {
+		return "", ErrCurrencyMismatch
+	}
+
+	debitBalance := debitAccount.apply(adj.Amount, 0)
+	creditBalance := creditAccount.apply(0, adj.Amount)
+	if debitBalance < 0 || creditBalance < 0 {
+		return "", ErrInsufficientBalance
+	}
+
+	var transactionID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transactions (user_id, idempotency_key, amount, currency, status, type, parent_transaction_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		constants.SystemUserID, "discrepancy-adjustment:"+discrepancyID, adj.Amount, debitAccount.currency, "completed", "adjustment", parentTxID,
+	).Scan(&transactionID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range []struct {
+		account       adjustmentAccount
+		debit, credit int64
+		balance       int64
+	}{
+		{debitAccount, adj.Amount, 0, debitBalance},
+		{creditAccount, 0, adj.Amount, creditBalance},
+	} {
+		if _, err := tx.Exec(ctx, "UPDATE wallets SET balance = $1, updated_at = NOW() WHERE id = $2", line.balance, line.account.id); err != nil {
+			return "", err
+		}
+		_, err = tx.Exec(ctx, "INSERT INTO ledger_entries (transaction_id,account_id,debit,credit,balance_after,description) VALUES ($1, $2, $3, $4, $5, $6)",
+			transactionID, line.account.id, line.debit, line.credit, line.balance, "adjustment")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return transactionID, nil
+}
@@ -0,0 +1,427 @@
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/internal/model"
+	"github.com/Niiaks/Aegis/internal/psp"
+	"github.com/Niiaks/Aegis/pkg/types"
+	"github.com/google/uuid"
+)
+
+// maxPspPages bounds how many pages of the Paystack transaction list a single
+// run will walk (100 transactions per page).
+const maxPspPages = 500
+
+type ReconciliationService struct {
+	repo     ReconciliationRepository
+	paystack psp.Client
+}
+
+func NewReconciliationService(repo ReconciliationRepository, paystack psp.Client) *ReconciliationService {
+	return &ReconciliationService{
+		repo:     repo,
+		paystack: paystack,
+	}
+}
+
+type RunResult struct {
+	Run           model.ReconciliationRun `json:"run"`
+	Checked       int                     `json:"checked"`
+	Discrepancies []model.Discrepancy     `json:"discrepancies"`
+}
+
+// QueueDaily asks the reconciliation worker to run RunDaily for day.
+func (rs *ReconciliationService) QueueDaily(ctx context.Context, day time.Time) error {
+	return rs.repo.QueueRun(ctx, day, middleware.GetRequestIDFromContext(ctx))
+}
+
+// RunDaily reconciles the payments created on the given UTC day against
+// Paystack's transaction list and against the ledger, then records the run.
+func (rs *ReconciliationService) RunDaily(ctx context.Context, day time.Time) (*RunResult, error) {
+	logger := middleware.GetLogger(ctx)
+
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	logger.Info().Time("from", from).Time("to", to).Msg("Starting reconciliation run")
+
+	charges, err := rs.repo.ListSettledCharges(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load charges: %w", err)
+	}
+
+	pspCharges, err := rs.fetchPspCharges(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load paystack transactions: %w", err)
+	}
+
+	ids := make([]uuid.UUID, 0, len(charges))
+	for _, c := range charges {
+		ids = append(ids, c.ID)
+	}
+	ledger, err := rs.repo.LedgerTotals(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ledger totals: %w", err)
+	}
+
+	var discrepancies []model.Discrepancy
+	seen := make(map[string]bool, len(charges))
+
+	for _, c := range charges {
+		seen[c.PspReference] = true
+
+		pspCharge, ok := pspCharges[c.PspReference]
+		if !ok && c.PspReference != "" {
+			// The charge may sit on the other side of a day boundary at
+			// Paystack, so confirm with a direct lookup before flagging it.
+			pspCharge, err = rs.verify(ctx, c.PspReference)
+			if err != nil {
+				return nil, err
+			}
+			ok = pspCharge != nil
+		}
+		discrepancies = append(discrepancies, comparePsp(c, pspCharge, ok)...)
+		discrepancies = append(discrepancies, compareLedger(c, ledger[c.ID])...)
+	}
+
+	// Successful charges Paystack knows about that were not in today's set.
+	var unmatched []string
+	for ref, p := range pspCharges {
+		if !seen[ref] && p.Status == "success" {
+			unmatched = append(unmatched, ref)
+		}
+	}
+	if len(unmatched) > 0 {
+		known, err := rs.repo.FindChargesByReference(ctx, unmatched)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up unmatched references: %w", err)
+		}
+		for _, ref := range unmatched {
+			p := pspCharges[ref]
+			if c, ok := known[ref]; ok && (c.Status == "completed" || c.Status == "refunded") {
+				continue
+			}
+			discrepancies = append(discrepancies, model.Discrepancy{
+				PspReference:   ref,
+				ExpectedAmount: p.Amount,
+				ActualAmount:   0,
+				ReasonCode:     ReasonMissingInAegis,
+				Reason:         "charge succeeded at Paystack but is not settled in Aegis",
+			})
+		}
+	}
+
+	run := model.ReconciliationRun{RunDate: from, Status: "matched", Source: "api"}
+	if len(discrepancies) > 0 {
+		run.Status = "discrepancy"
+	}
+
+	if err := rs.repo.SaveRun(ctx, &run, discrepancies, correlationID(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to save reconciliation run: %w", err)
+	}
+
+	logger.Info().
+		Str("run_id", run.ID.String()).
+		Str("status", run.Status).
+		Int("checked", len(charges)).
+		Int("discrepancies", len(discrepancies)).
+		Msg("Reconciliation run complete")
+
+	return &RunResult{Run: run, Checked: len(charges), Discrepancies: discrepancies}, nil
+}
+
+// ImportSettlement reconciles a Paystack settlement export for the given day.
+// Each line is matched to a charge by PSP reference and its gross amount, fees
+// and net settled amount are checked against what Aegis recorded.
+func (rs *ReconciliationService) ImportSettlement(ctx context.Context, r io.Reader, fileName string, day time.Time) (*RunResult, error) {
+	logger := middleware.GetLogger(ctx)
+
+	lines, err := ParseSettlementCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info().Str("file", fileName).Int("lines", len(lines)).Msg("Parsed settlement file")
+
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	dated := 0
+	for _, l := range lines {
+		if l.SettledOn.IsZero() {
+			continue
+		}
+		if !l.SettledOn.Equal(day) {
+			return nil, fmt.Errorf("%w: line %d settled on %s, not %s", ErrSettlementDate, l.Line, l.SettledOn.Format(time.DateOnly), day.Format(time.DateOnly))
+		}
+		dated++
+	}
+	if dated == 0 && len(lines) > 0 {
+		logger.Warn().Str("file", fileName).Msg("Settlement file has no date column, settlement date not checked")
+	}
+
+	refs := make([]string, 0, len(lines))
+	for _, l := range lines {
+		refs = append(refs, l.Reference)
+	}
+	charges, err := rs.repo.FindChargesByReference(ctx, refs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up charges: %w", err)
+	}
+	fees, err := rs.repo.WebhookFees(ctx, refs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up webhook fees: %w", err)
+	}
+
+	var discrepancies []model.Discrepancy
+	seen := make(map[string]int, len(lines))
+	for _, l := range lines {
+		if first, dup := seen[l.Reference]; dup {
+			discrepancies = append(discrepancies, model.Discrepancy{
+				PspReference:   l.Reference,
+				ExpectedAmount: 0,
+				ActualAmount:   l.Net,
+				ReasonCode:     ReasonDuplicateSettlement,
+				Reason:         fmt.Sprintf("line %d settles a reference already settled on line %d", l.Line, first),
+			})
+			continue
+		}
+		seen[l.Reference] = l.Line
+
+		c, ok := charges[l.Reference]
+		if !ok {
+			discrepancies = append(discrepancies, model.Discrepancy{
+				PspReference:   l.Reference,
+				ExpectedAmount: 0,
+				ActualAmount:   l.Gross,
+				ReasonCode:     ReasonMissingInAegis,
+				Reason:         fmt.Sprintf("line %d: settled by Paystack but no matching charge in Aegis", l.Line),
+			})
+			continue
+		}
+		discrepancies = append(discrepancies, compareSettlement(l, c, fees[l.Reference])...)
+	}
+
+	run := model.ReconciliationRun{RunDate: day, Status: "matched", Source: "settlement_file", SourceFile: fileName}
+	if len(discrepancies) > 0 {
+		run.Status = "discrepancy"
+	}
+
+	if err := rs.repo.SaveRun(ctx, &run, discrepancies, correlationID(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to save reconciliation run: %w", err)
+	}
+
+	logger.Info().
+		Str("run_id", run.ID.String()).
+		Str("status", run.Status).
+		Int("checked", len(lines)).
+		Int("discrepancies", len(discrepancies)).
+		Msg("Settlement import complete")
+
+	return &RunResult{Run: run, Checked: len(lines), Discrepancies: discrepancies}, nil
+}
+
+func compareSettlement(l SettlementLine, c Charge, webhookFees int64) []model.Discrepancy {
+	id := c.ID
+	var out []model.Discrepancy
+	add := func(code string, expected, actual int64, reason string) {
+		out = append(out, model.Discrepancy{
+			TransactionID:  &id,
+			PspReference:   l.Reference,
+			ExpectedAmount: expected,
+			ActualAmount:   actual,
+			ReasonCode:     code,
+			Reason:         fmt.Sprintf("line %d: %s", l.Line, reason),
+		})
+	}
+
+	if c.Status != "completed" && c.Status != "refunded" {
+		add(ReasonStatusMismatch, c.Amount, l.Gross, fmt.Sprintf("settled by Paystack but charge is %s in Aegis", c.Status))
+	}
+	if l.Currency != "" && l.Currency != c.Currency {
+		add(ReasonCurrencyMismatch, c.Amount, l.Gross, fmt.Sprintf("settled in %s but charged in %s", l.Currency, c.Currency))
+	}
+	if l.Gross != c.Amount {
+		add(ReasonAmountMismatch, c.Amount, l.Gross, "settled gross amount differs from charged amount")
+	}
+	if l.Fees != webhookFees {
+		add(ReasonFeeMismatch, webhookFees, l.Fees, "settled fees differ from fees reported in the webhook")
+	}
+	if expectedNet := c.Amount - webhookFees; l.Net != expectedNet {
+		add(ReasonNetMismatch, expectedNet, l.Net, "net settled amount differs from charged amount less fees")
+	}
+	return out
+}
+
+// correlationID reuses the request ID from the context when it is a UUID, as
+// transaction_outbox.correlation_id requires one.
+func correlationID(ctx context.Context) string {
+	id := middleware.GetRequestIDFromContext(ctx)
+	if _, err := uuid.Parse(id); err != nil {
+		return uuid.New().String()
+	}
+	return id
+}
+
+func (rs *ReconciliationService) fetchPspCharges(ctx context.Context, from, to time.Time) (map[string]*types.PaystackTransaction, error) {
+	charges := make(map[string]*types.PaystackTransaction)
+	for page := 1; page <= maxPspPages; page++ {
+		res, err := rs.paystack.ListTransactions(ctx, from, to, page)
+		if err != nil {
+			return nil, err
+		}
+		for i := range res.Data {
+			charges[res.Data[i].Reference] = &res.Data[i]
+		}
+		if page >= res.Meta.PageCount || len(res.Data) == 0 {
+			return charges, nil
+		}
+	}
+	return nil, fmt.Errorf("paystack transaction list exceeded %d pages", maxPspPages)
+}
+
+// verify returns nil when Paystack does not know the reference. Any other
+// failure, including other rejections, fails the run rather than flag a charge
+// as missing.
+func (rs *ReconciliationService) verify(ctx context.Context, reference string) (*types.PaystackTransaction, error) {
+	res, err := rs.paystack.VerifyTransaction(ctx, reference)
+	if errors.Is(err, psp.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify %s: %w", reference, err)
+	}
+	return &res.Data, nil
+}
+
+func comparePsp(c Charge, p *types.PaystackTransaction, found bool) []model.Discrepancy {
+	id := c.ID
+	if !found {
+		return []model.Discrepancy{{
+			TransactionID:  &id,
+			PspReference:   c.PspReference,
+			ExpectedAmount: c.Amount,
+			ActualAmount:   0,
+			ReasonCode:     ReasonMissingAtPSP,
+			Reason:         "charge is settled in Aegis but Paystack has no record of it",
+		}}
+	}
+
+	var out []model.Discrepancy
+	// A fully refunded charge shows up as reversed at Paystack.
+	if p.Status != "success" && !(c.Status == "refunded" && p.Status == "reversed") {
+		out = append(out, model.Discrepancy{
+			TransactionID:  &id,
+			PspReference:   c.PspReference,
+			ExpectedAmount: c.Amount,
+			ActualAmount:   0,
+			ReasonCode:     ReasonStatusMismatch,
+			Reason:         fmt.Sprintf("aegis status %q but paystack status %q", c.Status, p.Status),
+		})
+	}
+	if p.Amount != c.Amount {
+		out = append(out, model.Discrepancy{
+			TransactionID:  &id,
+			PspReference:   c.PspReference,
+			ExpectedAmount: c.Amount,
+			ActualAmount:   p.Amount,
+			ReasonCode:     ReasonAmountMismatch,
+			Reason:         "charged amount differs from paystack",
+		})
+	}
+	if p.Currency != "" && p.Currency != c.Currency {
+		out = append(out, model.Discrepancy{
+			TransactionID:  &id,
+			PspReference:   c.PspReference,
+			ExpectedAmount: c.Amount,
+			ActualAmount:   p.Amount,
+			ReasonCode:     ReasonCurrencyMismatch,
+			Reason:         fmt.Sprintf("aegis currency %s but paystack currency %s", c.Currency, p.Currency),
+		})
+	}
+	return out
+}
+
+// compareLedger checks the charge's ledger lines balance and that the
+// external account was debited the gross amount.
+func compareLedger(c Charge, t LedgerTotal) []model.Discrepancy {
+	id := c.ID
+	var out []model.Discrepancy
+	if t.Debit != t.Credit {
+		out = append(out, model.Discrepancy{
+			TransactionID:  &id,
+			PspReference:   c.PspReference,
+			ExpectedAmount: t.Debit,
+			ActualAmount:   t.Credit,
+			ReasonCode:     ReasonLedgerImbalance,
+			Reason:         "ledger debits and credits do not balance",
+		})
+	}
+	if t.ExternalDebit != c.Amount {
+		out = append(out, model.Discrepancy{
+			TransactionID:  &id,
+			PspReference:   c.PspReference,
+			ExpectedAmount: c.Amount,
+			ActualAmount:   t.ExternalDebit,
+			ReasonCode:     ReasonLedgerAmountMismatch,
+			Reason:         "ledger inflow differs from charged amount",
+		})
+	}
+	return out
+}
+
+type DiscrepancyPage struct {
+	Data       []model.Discrepancy `json:"data"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+func (rs *ReconciliationService) ListDiscrepancies(ctx context.Context, filter *DiscrepancyFilter) (*DiscrepancyPage, error) {
+	// Fetch one extra row to know whether another page exists.
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	discrepancies, err := rs.repo.ListDiscrepancies(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &DiscrepancyPage{Data: discrepancies}
+	if len(discrepancies) > limit {
+		page.Data = discrepancies[:limit]
+		last := page.Data[limit-1]
+		page.NextCursor = types.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return page, nil
+}
+
+func (rs *ReconciliationService) GetDiscrepancy(ctx context.Context, id string) (*model.Discrepancy, error) {
+	return rs.repo.GetDiscrepancy(ctx, id)
+}
+
+func (rs *ReconciliationService) AssignDiscrepancy(ctx context.Context, id string, req *AssignRequest) (*model.Discrepancy, error) {
+	return rs.repo.AssignDiscrepancy(ctx, id, req.AssignedTo)
+}
+
+// ResolveDiscrepancy closes a discrepancy, posting the balancing adjustment
+// through the ledger when one is supplied.
+func (rs *ReconciliationService) ResolveDiscrepancy(ctx context.Context, id string, req *ResolveRequest) (*model.Discrepancy, error) {
+	logger := middleware.GetLogger(ctx)
+
+	d, err := rs.repo.ResolveDiscrepancy(ctx, id, req)
+	if err != nil {
+		return nil, err
+	}
+
+	event := logger.Info().
+		Str("discrepancy_id", id).
+		Str("resolution_type", req.ResolutionType).
+		Str("resolved_by", req.ResolvedBy)
+	if d.AdjustmentTxID != nil {
+		event = event.Str("adjustment_transaction_id", d.AdjustmentTxID.String())
+	}
+	event.Msg("Discrepancy resolved")
+
+	return d, nil
+}
@@ -0,0 +1,173 @@
+package reconciliation
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SettlementLine is one transaction from a Paystack settlement export.
+// Amounts are converted to minor units.
+type SettlementLine struct {
+	Line      int
+	Reference string
+	Currency  string
+	Gross     int64
+	Fees      int64
+	Net       int64
+	// SettledOn is the line's settlement date, or zero when the export has no
+	// date column.
+	SettledOn time.Time
+}
+
+// settlementColumns maps each field to the header names Paystack has used for
+// it in settlement exports. Headers are matched case-insensitively.
+var settlementColumns = map[string][]string{
+	"reference": {"reference", "transaction reference"},
+	"currency":  {"currency"},
+	"gross":     {"amount", "transaction amount", "gross amount"},
+	"fees":      {"fees", "fee", "paystack fees"},
+	"net":       {"settled amount", "amount settled", "net amount", "net"},
+	"date":      {"settlement date", "settled date", "settled at", "date"},
+}
+
+// settlementDateLayouts are the date formats accepted in the date column.
+var settlementDateLayouts = []string{time.DateOnly, time.DateTime, time.RFC3339}
+
+// ParseSettlementCSV reads a settlement export. The currency and date columns
+// are optional; every other column is required. Amounts are expected in major
+// units with at most two decimal places, as Paystack exports them.
+func ParseSettlementCSV(r io.Reader) ([]SettlementLine, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settlement header: %w", err)
+	}
+
+	index := make(map[string]int, len(settlementColumns))
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		for field, aliases := range settlementColumns {
+			for _, alias := range aliases {
+				if name == alias {
+					if _, dup := index[field]; !dup {
+						index[field] = i
+					}
+				}
+			}
+		}
+	}
+	for _, field := range []string{"reference", "gross", "fees", "net"} {
+		if _, ok := index[field]; !ok {
+			return nil, fmt.Errorf("settlement file is missing a %s column", field)
+		}
+	}
+
+	var lines []SettlementLine
+	for lineNo := 2; ; lineNo++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		line := SettlementLine{Line: lineNo, Reference: strings.TrimSpace(record[index["reference"]])}
+		if line.Reference == "" {
+			return nil, fmt.Errorf("line %d: missing reference", lineNo)
+		}
+		if i, ok := index["currency"]; ok {
+			line.Currency = strings.ToUpper(strings.TrimSpace(record[i]))
+		}
+		if i, ok := index["date"]; ok {
+			line.SettledOn, err = parseSettlementDate(record[i])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid date %q", lineNo, record[i])
+			}
+		}
+		amounts := []struct {
+			field string
+			dst   *int64
+		}{{"gross", &line.Gross}, {"fees", &line.Fees}, {"net", &line.Net}}
+		for _, a := range amounts {
+			v, err := parseMinorUnits(record[index[a.field]])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s %q: %w", lineNo, a.field, record[index[a.field]], err)
+			}
+			*a.dst = v
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// parseSettlementDate returns the UTC calendar day of s.
+func parseSettlementDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range settlementDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			t = t.UTC()
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, errors.New("unrecognised date")
+}
+
+// parseMinorUnits converts a decimal string such as "1,500.50" or ".50" to
+// minor units without going through floating point.
+func parseMinorUnits(s string) (int64, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	if s == "" {
+		return 0, errors.New("empty amount")
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, errors.New("no digits")
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, errors.New("not a decimal number")
+	}
+	if len(frac) > 2 {
+		return 0, errors.New("more than two decimal places")
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+
+	var major int64
+	if whole != "" {
+		var err error
+		if major, err = strconv.ParseInt(whole, 10, 64); err != nil {
+			return 0, err
+		}
+	}
+	if major > (math.MaxInt64-99)/100 {
+		return 0, errors.New("amount out of range")
+	}
+	minor, _ := strconv.ParseInt(frac, 10, 64)
+
+	v := major*100 + minor
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package reconciliation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMinorUnits(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "1500", want: 150000},
+		{in: "1,500.50", want: 150050},
+		{in: " 12.5 ", want: 1250},
+		{in: ".50", want: 50},
+		{in: "0.05", want: 5},
+		{in: "1.", want: 100},
+		{in: "-3.25", want: -325},
+		{in: "-.75", want: -75},
+		{in: "", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "1.234", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "1.-5", wantErr: true},
+		{in: "--5", wantErr: true},
+		{in: "+5", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "92233720368547758.07", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseMinorUnits(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseMinorUnits(%q) = %d, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseMinorUnits(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseMinorUnits(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSettlementCSV(t *testing.T) {
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		csv     string
+		want    []SettlementLine
+		wantErr string
+	}{
+		{
+			name: "canonical headers",
+			csv:  "Reference,Currency,Amount,Fees,Settled Amount\nref_1,ghs,100.00,1.95,98.05\nref_2,GHS,.50,0,.50\n",
+			want: []SettlementLine{
+				{Line: 2, Reference: "ref_1", Currency: "GHS", Gross: 10000, Fees: 195, Net: 9805},
+				{Line: 3, Reference: "ref_2", Currency: "GHS", Gross: 50, Fees: 0, Net: 50},
+			},
+		},
+		{
+			name: "aliases, BOM and no currency",
+			csv:  "\ufeffTransaction Reference, Gross Amount, Paystack Fees, Net Amount\nref_1, \"1,000.00\", 15, 985\n",
+			want: []SettlementLine{
+				{Line: 2, Reference: "ref_1", Gross: 100000, Fees: 1500, Net: 98500},
+			},
+		},
+		{
+			name: "date column",
+			csv:  "reference,amount,fees,net,settlement date\nref_1,10,0,10,2026-10-17\nref_2,10,0,10,2026-10-17T09:30:00Z\nref_3,10,0,10,2026-10-17 23:59:59\n",
+			want: []SettlementLine{
+				{Line: 2, Reference: "ref_1", Gross: 1000, Net: 1000, SettledOn: day},
+				{Line: 3, Reference: "ref_2", Gross: 1000, Net: 1000, SettledOn: day},
+				{Line: 4, Reference: "ref_3", Gross: 1000, Net: 1000, SettledOn: day},
+			},
+		},
+		{
+			name: "header only",
+			csv:  "reference,amount,fees,net\n",
+		},
+		{
+			name:    "empty file",
+			csv:     "",
+			wantErr: "failed to read settlement header",
+		},
+		{
+			name:    "missing net column",
+			csv:     "reference,amount,fees\nref_1,10,0\n",
+			wantErr: "missing a net column",
+		},
+		{
+			name:    "missing reference",
+			csv:     "reference,amount,fees,net\n ,10,0,10\n",
+			wantErr: "line 2: missing reference",
+		},
+		{
+			name:    "bad amount",
+			csv:     "reference,amount,fees,net\nref_1,10.001,0,10\n",
+			wantErr: `line 2: invalid gross "10.001"`,
+		},
+		{
+			name:    "bad date",
+			csv:     "reference,amount,fees,net,date\nref_1,10,0,10,17/10/2026\n",
+			wantErr: `line 2: invalid date "17/10/2026"`,
+		},
+		{
+			name:    "short row",
+			csv:     "reference,amount,fees,net\nref_1,10\n",
+			wantErr: "line 2:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSettlementCSV(strings.NewReader(tt.csv))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseSettlementCSV() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSettlementCSV() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSettlementCSV() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
@@ -13,6 +14,8 @@ import (
 type Client struct {
 	rdb       *redis.Client
 	keyPrefix string
+	lockTTL   time.Duration
+	lockWait  time.Duration
 	log       *zerolog.Logger
 }
 
@@ -37,6 +40,8 @@ func New(log *zerolog.Logger, cfg *config.RedisConfig) (*Client, error) {
 	return &Client{
 		rdb:       rdb,
 		keyPrefix: cfg.KeyPrefix,
+		lockTTL:   cfg.LockTTL,
+		lockWait:  cfg.LockWait,
 		log:       log,
 	}, nil
 }
@@ -2,7 +2,9 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -13,10 +15,15 @@ var (
 	ErrKeyNotFound = errors.New("idempotency key not found")
 )
 
-// IdempotencyResult stores the result of an idempotent operation
+// IdempotencyResult stores the state of an idempotent operation: the
+// fingerprint of the request that claimed the key and, once completed, the
+// response to replay.
 type IdempotencyResult struct {
-	Status   string // "pending", "completed", "failed"
-	Response []byte // Cached response if completed
+	Status      string      `json:"status"` // "pending", "completed"
+	RequestHash string      `json:"request_hash"`
+	StatusCode  int         `json:"status_code,omitempty"`
+	Headers     http.Header `json:"headers,omitempty"`
+	Response    []byte      `json:"response,omitempty"` // Cached response if completed
 }
 
 // SetIdempotencyKey sets a key if it doesn't exist (for idempotency check)
@@ -46,10 +53,15 @@ func (c *Client) GetIdempotencyKey(ctx context.Context, key string) (string, err
 }
 
 // MarkIdempotencyComplete marks an idempotent operation as completed with response
-func (c *Client) MarkIdempotencyComplete(ctx context.Context, key string, response []byte, ttl time.Duration) error {
+func (c *Client) MarkIdempotencyComplete(ctx context.Context, key string, result *IdempotencyResult, ttl time.Duration) error {
 	prefixedKey := c.prefixKey("idempotency:" + key)
 
-	return c.rdb.Set(ctx, prefixedKey, response, ttl).Err()
+	result.Status = "completed"
+	value, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return c.rdb.Set(ctx, prefixedKey, value, ttl).Err()
 }
 
 func (c *Client) MarkIdempotencyFailed(ctx context.Context, key string) error {
@@ -58,11 +70,18 @@ func (c *Client) MarkIdempotencyFailed(ctx context.Context, key string) error {
 	return c.rdb.Del(ctx, prefixedKey).Err()
 }
 
-// CheckAndSetIdempotency is a helper that checks if operation was already done
-func (c *Client) CheckAndSetIdempotency(ctx context.Context, key string, ttl time.Duration) ([]byte, error) {
+// CheckAndSetIdempotency claims key for the request fingerprinted by
+// requestHash. It returns nil, nil when the key was free; otherwise the stored
+// result, with ErrKeyExists if that request is still pending. Callers compare
+// RequestHash to detect a key reused for a different request.
+func (c *Client) CheckAndSetIdempotency(ctx context.Context, key, requestHash string, ttl time.Duration) (*IdempotencyResult, error) {
 	prefixedKey := c.prefixKey("idempotency:" + key)
 
-	set, err := c.rdb.SetNX(ctx, prefixedKey, "pending", ttl).Result()
+	pending, err := json.Marshal(IdempotencyResult{Status: "pending", RequestHash: requestHash})
+	if err != nil {
+		return nil, err
+	}
+	set, err := c.rdb.SetNX(ctx, prefixedKey, pending, ttl).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -76,9 +95,24 @@ func (c *Client) CheckAndSetIdempotency(ctx context.Context, key string, ttl tim
 		return nil, err
 	}
 
-	if val == "pending" {
-		return nil, ErrKeyExists
+	result := parseIdempotencyResult(val)
+	if result.Status == "pending" {
+		return result, ErrKeyExists
 	}
 
-	return []byte(val), nil
+	return result, nil
+}
+
+// parseIdempotencyResult also reads values written before results were
+// stored as JSON: "pending", or the bare response body. Those carry no
+// fingerprint.
+func parseIdempotencyResult(val string) *IdempotencyResult {
+	var result IdempotencyResult
+	if err := json.Unmarshal([]byte(val), &result); err == nil && result.Status != "" {
+		return &result
+	}
+	if val == "pending" {
+		return &IdempotencyResult{Status: "pending"}
+	}
+	return &IdempotencyResult{Status: "completed", Response: []byte(val)}
 }
@@ -2,48 +2,181 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrLockNotAcquired = errors.New("lock not acquired before deadline")
+	ErrLockLost        = errors.New("lock lost")
+)
+
+const (
+	lockRetryMin = 10 * time.Millisecond
+	lockRetryMax = 500 * time.Millisecond
 )
 
-// Lock represents a distributed lock
+// acquireScript takes the lock and, only when it succeeds, bumps the key's
+// fencing counter, first raising it to ARGV[3] if it is lower. The counter
+// never expires, so tokens keep increasing across holders.
+var acquireScript = redis.NewScript(`
+	if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+		if tonumber(redis.call("get", KEYS[2]) or "0") < tonumber(ARGV[3]) then
+			redis.call("set", KEYS[2], ARGV[3])
+		end
+		return redis.call("incr", KEYS[2])
+	end
+	return 0
+`)
+
+var renewScript = redis.NewScript(`
+	if redis.call("get", KEYS[1]) == ARGV[1] then
+		return redis.call("pexpire", KEYS[1], ARGV[2])
+	end
+	return 0
+`)
+
+// Simple script to ensure only the owner can release the lock
+var releaseScript = redis.NewScript(`
+	if redis.call("get", KEYS[1]) == ARGV[1] then
+		return redis.call("del", KEYS[1])
+	end
+	return 0
+`)
+
+// Lock represents a distributed lock. While held it is renewed in the
+// background every third of its TTL, so it outlives the TTL as long as the
+// holder is alive. Writes it guards should carry Token so a holder that lost
+// the lock (e.g. after a long pause) cannot overwrite a newer holder's work.
 type Lock struct {
 	client *Client
 	key    string
 	value  string
+	token  int64
+	ttl    time.Duration
+
+	stop     chan struct{}
+	done     chan struct{}
+	lost     chan struct{}
+	lostOnce sync.Once
+	stopOnce sync.Once
 }
 
-// AcquireLock attempts to acquire a distributed lock with a timeout
+// AcquireLock acquires a distributed lock, retrying with jittered exponential
+// backoff until the configured lock wait or ctx's deadline, whichever comes
+// first. A ttl of zero uses the configured lock TTL.
 func (c *Client) AcquireLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
-	prefixedKey := c.prefixKey("lock:" + key)
-	value := fmt.Sprintf("%d", time.Now().UnixNano())
+	return c.AcquireFencedLock(ctx, key, ttl, 0)
+}
 
-	ok, err := c.rdb.SetNX(ctx, prefixedKey, value, ttl).Result()
-	if err != nil {
-		return nil, fmt.Errorf("failed to acquire lock: %w", err)
+// AcquireFencedLock is AcquireLock with a token above floor, the highest token
+// already stored by the writes the lock guards. It keeps tokens increasing when
+// Redis lost the key's counter, e.g. after a flush or failover.
+func (c *Client) AcquireFencedLock(ctx context.Context, key string, ttl time.Duration, floor int64) (*Lock, error) {
+	if ttl <= 0 {
+		ttl = c.lockTTL
 	}
+	prefixedKey := c.prefixKey("lock:" + key)
+	fenceKey := c.prefixKey("lock:" + key + ":fence")
+	value := uuid.NewString()
 
-	if !ok {
-		return nil, fmt.Errorf("lock already held")
+	waitCtx, cancel := context.WithTimeout(ctx, c.lockWait)
+	defer cancel()
+
+	backoff := lockRetryMin
+	for {
+		token, err := acquireScript.Run(waitCtx, c.rdb, []string{prefixedKey, fenceKey}, value, ttl.Milliseconds(), floor).Int64()
+		if err != nil && waitCtx.Err() == nil {
+			return nil, fmt.Errorf("failed to acquire lock: %w", err)
+		}
+		if token > 0 {
+			l := &Lock{
+				client: c,
+				key:    prefixedKey,
+				value:  value,
+				token:  token,
+				ttl:    ttl,
+				stop:   make(chan struct{}),
+				done:   make(chan struct{}),
+				lost:   make(chan struct{}),
+			}
+			go l.renew()
+			return l, nil
+		}
+
+		// Full jitter keeps workers contending for the same wallet from
+		// retrying in lockstep.
+		select {
+		case <-waitCtx.Done():
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %s", ErrLockNotAcquired, key)
+		case <-time.After(rand.N(backoff) + 1):
+		}
+		backoff = min(backoff*2, lockRetryMax)
 	}
+}
+
+// Token returns the lock's fencing token. Tokens for a key increase with every
+// acquisition, so a write carrying a lower token than the last one stored is
+// from a holder that lost the lock.
+func (l *Lock) Token() int64 {
+	return l.token
+}
+
+// Lost is closed when a renewal finds the lock is no longer held.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *Lock) renew() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
 
-	return &Lock{
-		client: c,
-		key:    prefixedKey,
-		value:  value,
-	}, nil
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), l.ttl/3)
+		n, err := renewScript.Run(ctx, l.client.rdb, []string{l.key}, l.value, l.ttl.Milliseconds()).Int64()
+		cancel()
+		if err != nil {
+			// Transient: the lock stays valid until its TTL, so try again
+			l.client.log.Warn().Err(err).Str("key", l.key).Msg("Failed to renew lock")
+			continue
+		}
+		if n == 0 {
+			l.client.log.Error().Str("key", l.key).Int64("fence_token", l.token).Msg("Lock expired before renewal")
+			l.lostOnce.Do(func() { close(l.lost) })
+			return
+		}
+	}
 }
 
-// Release releases the lock if it is still held by the owner
+// Release stops renewal and releases the lock if it is still held by the
+// owner. It returns ErrLockLost if it was not.
 func (l *Lock) Release(ctx context.Context) error {
-	// Simple script to ensure only the owner can release the lock
-	script := `
-	if redis.call("get", KEYS[1]) == ARGV[1] then
-		return redis.call("del", KEYS[1])
-	else
-		return 0
-	end
-	`
-	_, err := l.client.rdb.Eval(ctx, script, []string{l.key}, l.value).Result()
-	return err
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+
+	n, err := releaseScript.Run(context.WithoutCancel(ctx), l.client.rdb, []string{l.key}, l.value).Int64()
+	if err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", ErrLockLost, l.key)
+	}
+	return nil
 }
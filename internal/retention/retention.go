@@ -0,0 +1,221 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/rs/zerolog"
+)
+
+// target describes one table the job prunes. Statements on tables with a
+// status filter on status = 'processed', so pending, failed, error and dlq rows
+// are never touched whatever their age.
+type target struct {
+	name    string
+	age     func(config.RetentionConfig) time.Duration
+	count   string
+	archive string
+	delete  string
+}
+
+var targets = []target{
+	{
+		name: "transaction_outbox",
+		age:  func(c config.RetentionConfig) time.Duration { return c.OutboxAge },
+		count: `
+			SELECT COUNT(*) FROM transaction_outbox
+			WHERE status = 'processed' AND updated_at < $1`,
+		archive: `
+			WITH batch AS (
+				SELECT id FROM transaction_outbox
+				WHERE status = 'processed' AND updated_at < $1
+				ORDER BY id
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			), moved AS (
+				DELETE FROM transaction_outbox o
+				USING batch
+				WHERE o.id = batch.id AND o.status = 'processed'
+				RETURNING o.id, o.event_type, o.payload, o.partition_key, o.status, o.correlation_id,
+					o.retry_count, o.last_error, o.next_retry_at, o.max_retries, o.created_at, o.updated_at
+			)
+			INSERT INTO transaction_outbox_archive (id, event_type, payload, partition_key, status, correlation_id,
+				retry_count, last_error, next_retry_at, max_retries, created_at, updated_at)
+			SELECT * FROM moved
+			ON CONFLICT (id) DO NOTHING`,
+		delete: `
+			DELETE FROM transaction_outbox
+			WHERE id IN (
+				SELECT id FROM transaction_outbox
+				WHERE status = 'processed' AND updated_at < $1
+				ORDER BY id
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			) AND status = 'processed'`,
+	},
+	{
+		name: "psp_webhooks",
+		age:  func(c config.RetentionConfig) time.Duration { return c.WebhookAge },
+		count: `
+			SELECT COUNT(*) FROM psp_webhooks
+			WHERE status = 'processed' AND created_at < $1`,
+		archive: `
+			WITH batch AS (
+				SELECT id FROM psp_webhooks
+				WHERE status = 'processed' AND created_at < $1
+				ORDER BY created_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			), moved AS (
+				DELETE FROM psp_webhooks w
+				USING batch
+				WHERE w.id = batch.id AND w.status = 'processed'
+				RETURNING w.id, w.event_id, w.payload, w.status, w.created_at, w.updated_at
+			)
+			INSERT INTO psp_webhooks_archive (id, event_id, payload, status, created_at, updated_at)
+			SELECT * FROM moved
+			ON CONFLICT (id) DO NOTHING`,
+		delete: `
+			DELETE FROM psp_webhooks
+			WHERE id IN (
+				SELECT id FROM psp_webhooks
+				WHERE status = 'processed' AND created_at < $1
+				ORDER BY created_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			) AND status = 'processed'`,
+	},
+	{
+		// Dedupe records are worthless once the topic no longer holds the
+		// offsets they guard, so they are deleted rather than archived.
+		name: "processed_messages",
+		age:  func(c config.RetentionConfig) time.Duration { return c.ProcessedMessageAge },
+		count: `
+			SELECT COUNT(*) FROM processed_messages
+			WHERE processed_at < $1`,
+		archive: `
+			DELETE FROM processed_messages
+			WHERE (consumer_group, topic, kafka_partition, kafka_offset) IN (
+				SELECT consumer_group, topic, kafka_partition, kafka_offset FROM processed_messages
+				WHERE processed_at < $1
+				ORDER BY processed_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)`,
+		delete: `
+			DELETE FROM processed_messages
+			WHERE (consumer_group, topic, kafka_partition, kafka_offset) IN (
+				SELECT consumer_group, topic, kafka_partition, kafka_offset FROM processed_messages
+				WHERE processed_at < $1
+				ORDER BY processed_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)`,
+	},
+}
+
+// Result reports rows archived or deleted per table. In dry-run mode it holds
+// the number of rows that would have been.
+type Result map[string]int64
+
+type Job struct {
+	db     *pgxpool.Pool
+	logger *zerolog.Logger
+	nrApp  *newrelic.Application
+	cfg    config.RetentionConfig
+}
+
+// NewJob builds a retention job. nrApp may be nil, in which case counts are
+// only logged.
+func NewJob(db *pgxpool.Pool, logger *zerolog.Logger, nrApp *newrelic.Application, cfg config.RetentionConfig) *Job {
+	return &Job{
+		db:     db,
+		logger: logger,
+		nrApp:  nrApp,
+		cfg:    cfg,
+	}
+}
+
+// Start runs the job immediately and then every configured interval until ctx
+// is cancelled.
+func (j *Job) Start(ctx context.Context) error {
+	j.logger.Info().Str("mode", j.cfg.Mode).Bool("dry_run", j.cfg.DryRun).Msg("Starting Retention Job")
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Run(ctx); err != nil && ctx.Err() == nil {
+			j.logger.Error().Err(err).Msg("Retention run failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			j.logger.Info().Msg("Stopping Retention Job")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run prunes every table once, in batches of BatchSize rows, each batch in its
+// own short transaction so locks are never held for long.
+func (j *Job) Run(ctx context.Context) (Result, error) {
+	result := Result{}
+	for _, t := range targets {
+		cutoff := time.Now().Add(-t.age(j.cfg))
+
+		n, err := j.prune(ctx, t, cutoff)
+		result[t.name] = n
+		j.record(t.name, n)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (j *Job) prune(ctx context.Context, t target, cutoff time.Time) (int64, error) {
+	if j.cfg.DryRun {
+		var n int64
+		if err := j.db.QueryRow(ctx, t.count, cutoff).Scan(&n); err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+
+	query := t.archive
+	if j.cfg.Mode == "delete" {
+		query = t.delete
+	}
+
+	var total int64
+	for ctx.Err() == nil {
+		tag, err := j.db.Exec(ctx, query, cutoff, j.cfg.BatchSize)
+		if err != nil {
+			return total, err
+		}
+		total += tag.RowsAffected()
+		if tag.RowsAffected() < int64(j.cfg.BatchSize) {
+			break
+		}
+	}
+	return total, ctx.Err()
+}
+
+func (j *Job) record(table string, n int64) {
+	action := "archived"
+	switch {
+	case j.cfg.DryRun:
+		action = "eligible"
+	case j.cfg.Mode == "delete":
+		action = "deleted"
+	}
+
+	j.logger.Info().Str("table", table).Str("action", action).Int64("rows", n).Msg("Retention pass complete")
+	if j.nrApp != nil {
+		j.nrApp.RecordCustomMetric("Retention/"+table+"/"+action, float64(n))
+	}
+}
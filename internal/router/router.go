@@ -2,19 +2,24 @@ package router
 
 import (
 	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/internal/outbox"
+	"github.com/Niiaks/Aegis/internal/reconciliation"
 	"github.com/Niiaks/Aegis/internal/server"
 	"github.com/Niiaks/Aegis/internal/transaction"
 	"github.com/Niiaks/Aegis/internal/user"
 	"github.com/Niiaks/Aegis/internal/wallet"
 	"github.com/Niiaks/Aegis/internal/webhook"
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 )
 
 type Handlers struct {
-	User        *user.UserHandler
-	Wallet      *wallet.WalletHandler
-	Transaction *transaction.TransactionHandler
-	Webhook     *webhook.WebhookHandler
+	User           *user.UserHandler
+	Wallet         *wallet.WalletHandler
+	Transaction    *transaction.TransactionHandler
+	Reconciliation *reconciliation.ReconciliationHandler
+	Outbox         *outbox.OutboxHandler
+	Webhook        *webhook.WebhookHandler
 }
 
 func NewRouter(s *server.Server, h *Handlers) *chi.Mux {
@@ -24,16 +29,62 @@ func NewRouter(s *server.Server, h *Handlers) *chi.Mux {
 
 	// Apply middleware in order
 	r.Use(middleware.RequestID)
+	if s.Config.Server.TrustProxy {
+		r.Use(chimiddleware.RealIP)
+	}
 	r.Use(mw.Tracing.NewRelicMiddleware())
 	r.Use(mw.Tracing.EnhanceTracing)
 	r.Use(mw.ContextEnhancer.EnhanceContext)
 	r.Use(mw.Global.RequestLogger)
+	r.Use(mw.RateLimit.Limit(r))
 
 	// API routes
 	r.Route("/api/v1", func(r chi.Router) {
 		//payment routes
 		r.Route("/transactions", func(r chi.Router) {
-			r.Post("/payment-intent", h.Transaction.PaymentIntent)
+			r.Get("/", h.Transaction.ListTransactions)
+			r.Get("/{id}", h.Transaction.GetTransaction)
+
+			// Money-moving routes require an Idempotency-Key
+			r.Group(func(r chi.Router) {
+				r.Use(mw.Idempotency.Require)
+				r.Post("/payment-intent", h.Transaction.PaymentIntent)
+				r.Post("/payout", h.Transaction.Payout)
+				r.Post("/{id}/refunds", h.Transaction.Refund)
+			})
+		})
+
+		//user routes
+		r.Route("/users", func(r chi.Router) {
+			r.With(mw.Idempotency.Require).Post("/", h.User.CreateUser)
+			r.Get("/", h.User.ListUsers)
+			r.Get("/{id}", h.User.GetUser)
+			r.Patch("/{id}", h.User.UpdateUser)
+		})
+
+		//wallet routes
+		r.Route("/wallets", func(r chi.Router) {
+			r.Post("/", h.Wallet.CreateWallet)
+			r.Get("/", h.Wallet.ListWallets)
+			r.Get("/{id}", h.Wallet.GetWallet)
+			r.Get("/{id}/statement", h.Wallet.GetStatement)
+		})
+
+		//reconciliation routes
+		r.Route("/reconciliation", func(r chi.Router) {
+			r.Get("/discrepancies", h.Reconciliation.ListDiscrepancies)
+			r.Get("/discrepancies/{id}", h.Reconciliation.GetDiscrepancy)
+			r.Post("/discrepancies/{id}/assign", h.Reconciliation.AssignDiscrepancy)
+			r.Post("/discrepancies/{id}/resolve", h.Reconciliation.ResolveDiscrepancy)
+		})
+
+		//admin routes
+		r.Route("/admin/outbox", func(r chi.Router) {
+			r.Use(mw.Admin.Require)
+			r.Get("/", h.Outbox.ListEvents)
+			r.Get("/{id}", h.Outbox.GetEvent)
+			r.Post("/reset", h.Outbox.ResetEvents)
+			r.Post("/dlq/replay", h.Outbox.ReplayDLQ)
 		})
 
 		//webhook route
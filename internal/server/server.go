@@ -32,6 +32,10 @@ func NewServer(cfg *config.Config, logger *zerolog.Logger, ls *loggerPkg.LoggerS
 	}, nil
 }
 
+func (s *Server) Redis() *redis.Client {
+	return s.redis
+}
+
 func (s *Server) SetupHTTPServer(handler http.Handler) {
 	s.httpServer = &http.Server{
 		Addr:         ":" + s.Config.Server.Port,
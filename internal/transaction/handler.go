@@ -2,10 +2,15 @@ package transaction
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/Niiaks/Aegis/internal/idempotency"
 	"github.com/Niiaks/Aegis/internal/middleware"
 	"github.com/Niiaks/Aegis/pkg/types"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -28,13 +33,14 @@ func (th *TransactionHandler) PaymentIntent(w http.ResponseWriter, r *http.Reque
 	logger := middleware.GetLogger(ctx)
 	logger.Info().Msg("Received request to create payment intent")
 
-	//get idempotency key from header
-	idemKey := r.Header.Get("Idempotency-Key")
-	if idemKey == "" {
+	// Claimed by the idempotency middleware
+	idem := idempotency.FromContext(ctx)
+	if idem == nil {
 		logger.Error().Msg("Idempotency-Key header is missing")
 		http.Error(w, "Idempotency-Key header is required", http.StatusBadRequest)
 		return
 	}
+
 	var req types.InitializePaymentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger.Error().Err(err).Msg("Failed to decode payment intent request")
@@ -48,7 +54,7 @@ func (th *TransactionHandler) PaymentIntent(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	res, err := th.transactionService.PaymentIntent(ctx, &req, idemKey, requestID)
+	res, err := th.transactionService.PaymentIntent(ctx, &req, idem, requestID)
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to create payment intent")
 		http.Error(w, "Failed to create payment intent: "+err.Error(), http.StatusInternalServerError)
@@ -58,3 +64,200 @@ func (th *TransactionHandler) PaymentIntent(w http.ResponseWriter, r *http.Reque
 	json.NewEncoder(w).Encode(res)
 	logger.Info().Msg("Payment intent created successfully")
 }
+
+func (th *TransactionHandler) Payout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	requestID := middleware.GetRequestID(r)
+	logger := middleware.GetLogger(ctx)
+	logger.Info().Msg("Received request to create payout")
+
+	// Claimed by the idempotency middleware
+	idem := idempotency.FromContext(ctx)
+	if idem == nil {
+		logger.Error().Msg("Idempotency-Key header is missing")
+		http.Error(w, "Idempotency-Key header is required", http.StatusBadRequest)
+		return
+	}
+
+	var req types.PayoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode payout request")
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := validate.Struct(&req); err != nil {
+		logger.Error().Err(err).Msg("Validation error on payout request")
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := th.transactionService.Payout(ctx, &req, idem, requestID)
+	if errors.Is(err, ErrInsufficientBalance) {
+		logger.Warn().Err(err).Msg("Insufficient balance for payout")
+		http.Error(w, "Insufficient balance", http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to create payout")
+		http.Error(w, "Failed to create payout: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(res)
+	logger.Info().Str("transaction_id", res.TransactionID).Msg("Payout created successfully")
+}
+
+func (th *TransactionHandler) Refund(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	logger := middleware.GetLogger(ctx)
+	transactionID := chi.URLParam(r, "id")
+	logger.Info().Str("transaction_id", transactionID).Msg("Received request to refund transaction")
+
+	if err := validate.Var(transactionID, "required,uuid"); err != nil {
+		http.Error(w, "Invalid transaction id", http.StatusBadRequest)
+		return
+	}
+	// Claimed by the idempotency middleware
+	idem := idempotency.FromContext(ctx)
+	if idem == nil {
+		logger.Error().Msg("Idempotency-Key header is missing")
+		http.Error(w, "Idempotency-Key header is required", http.StatusBadRequest)
+		return
+	}
+
+	var req types.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode refund request")
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := validate.Struct(&req); err != nil {
+		logger.Error().Err(err).Msg("Validation error on refund request")
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := th.transactionService.Refund(ctx, transactionID, &req, idem)
+	switch {
+	case errors.Is(err, ErrTransactionNotFound):
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrNotRefundable):
+		http.Error(w, "Transaction cannot be refunded", http.StatusConflict)
+		return
+	case errors.Is(err, ErrRefundExceedsCharge), errors.Is(err, ErrInsufficientBalance), errors.Is(err, ErrRefundCurrency):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		logger.Error().Err(err).Msg("Failed to refund transaction")
+		http.Error(w, "Failed to refund transaction: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Refunds settle asynchronously; a pending one is settled once Paystack
+	// reports its outcome.
+	code := http.StatusCreated
+	if res.Status == "pending" {
+		code = http.StatusAccepted
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(res)
+	logger.Info().Str("refund_id", res.RefundID).Str("status", res.Status).Msg("Refund created successfully")
+}
+
+func (th *TransactionHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	logger := middleware.GetLogger(ctx)
+	transactionID := chi.URLParam(r, "id")
+	if err := validate.Var(transactionID, "required,uuid"); err != nil {
+		http.Error(w, "Invalid transaction id", http.StatusBadRequest)
+		return
+	}
+
+	res, err := th.transactionService.GetTransaction(ctx, transactionID)
+	if errors.Is(err, ErrTransactionNotFound) {
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to get transaction")
+		http.Error(w, "Failed to get transaction", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (th *TransactionHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	logger := middleware.GetLogger(ctx)
+	q := r.URL.Query()
+
+	filter := ListFilter{
+		UserID:       q.Get("user_id"),
+		Status:       q.Get("status"),
+		Type:         q.Get("type"),
+		Currency:     q.Get("currency"),
+		PspReference: q.Get("psp_reference"),
+		Limit:        50,
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+	var err error
+	if filter.CreatedFrom, err = parseTimeParam(q.Get("created_from")); err != nil {
+		http.Error(w, "Invalid created_from: expected RFC3339 timestamp", http.StatusBadRequest)
+		return
+	}
+	if filter.CreatedTo, err = parseTimeParam(q.Get("created_to")); err != nil {
+		http.Error(w, "Invalid created_to: expected RFC3339 timestamp", http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := types.DecodeCursor(v)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		filter.Cursor = cursor
+	}
+
+	if err := validate.Struct(&filter); err != nil {
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := th.transactionService.ListTransactions(ctx, &filter)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list transactions")
+		http.Error(w, "Failed to list transactions", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// parseTimeParam parses an optional RFC3339 query parameter.
+func parseTimeParam(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -2,9 +2,21 @@ package transaction
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/Niiaks/Aegis/internal/events"
+	"github.com/Niiaks/Aegis/internal/idempotency"
+	"github.com/Niiaks/Aegis/internal/kafka"
+	"github.com/Niiaks/Aegis/internal/model"
+	"github.com/Niiaks/Aegis/internal/outbox"
+	"github.com/Niiaks/Aegis/internal/redis"
+	"github.com/Niiaks/Aegis/internal/wallet"
+	"github.com/Niiaks/Aegis/pkg/constants"
 	"github.com/Niiaks/Aegis/pkg/types"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -12,21 +24,89 @@ var Event string
 
 var PaymentIntentEvent = "aegis.payment.created"
 
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrNotRefundable       = errors.New("transaction cannot be refunded")
+	ErrRefundExceedsCharge = errors.New("refund exceeds remaining refundable amount")
+	// ErrRefundCurrency means the charge is in a currency the system accounts
+	// cannot post refunds in.
+	ErrRefundCurrency = errors.New("refunds are only supported in " + constants.SystemCurrency)
+)
+
+// Refund describes a refund that has been reserved against a completed charge
+// and is waiting for the PSP to confirm it.
+type Refund struct {
+	ID            string
+	TransactionID string
+	UserID        string
+	WalletID      string
+	// PspReference is the refunded charge's Paystack reference.
+	PspReference string
+	// PspRefundID is Paystack's ID for the refund, empty until Paystack has
+	// acknowledged it.
+	PspRefundID    string
+	Status         string
+	Currency       string
+	Amount         int64
+	SellerAmount   int64
+	PlatformAmount int64
+	ChargeAmount   int64
+	RefundedTotal  int64
+	CreatedAt      time.Time
+}
+
+// split divides the refund between seller and platform in the same
+// proportion as the charge's fee split.
+func (r *Refund) split(platformCredit int64) {
+	r.PlatformAmount = r.Amount * platformCredit / r.ChargeAmount
+	r.SellerAmount = r.Amount - r.PlatformAmount
+}
+
 type TransactionRepository interface {
-	PaymentIntent(ctx context.Context, request *types.InitializePaymentRequest, idempotencyKey, correlationID string) (string, error)
+	PaymentIntent(ctx context.Context, request *types.InitializePaymentRequest, idem *model.IdempotencyKey, correlationID string) (string, error)
+	FailPaymentIntent(ctx context.Context, transactionID string, idem *model.IdempotencyKey, reason string) error
+	Payout(ctx context.Context, request *types.PayoutRequest, idem *model.IdempotencyKey, correlationID string) (string, error)
+	CreateRefund(ctx context.Context, transactionID string, request *types.RefundRequest, idem *model.IdempotencyKey) (*Refund, error)
+	GetRefund(ctx context.Context, refundID string) (*Refund, error)
+	SetRefundReference(ctx context.Context, refundID, pspRefundID string) error
+	ListPendingRefunds(ctx context.Context, updatedBefore time.Time, limit int) ([]string, error)
+	ListPendingRefundsForCharge(ctx context.Context, chargeReference string) ([]string, error)
+	LinkedRefundReferences(ctx context.Context, chargeID string) ([]string, error)
+	CompleteRefund(ctx context.Context, refund *Refund, pspReference string) error
+	FailRefund(ctx context.Context, refund *Refund, reason string) error
+	GetTransaction(ctx context.Context, id string) (*model.Transaction, error)
+	GetLedgerEntries(ctx context.Context, transactionID string) ([]model.LedgerEntry, error)
+	ListTransactions(ctx context.Context, filter *ListFilter) ([]model.Transaction, error)
+}
+
+// ListFilter narrows a transaction listing. Results are ordered newest first
+// and paged with a keyset cursor on (created_at, id).
+type ListFilter struct {
+	UserID       string `validate:"omitempty,uuid"`
+	Status       string `validate:"omitempty,oneof=pending completed failed refunded"`
+	Type         string `validate:"omitempty,oneof=payment_intent payout refund fee adjustment"`
+	Currency     string `validate:"omitempty,len=3"`
+	PspReference string
+	CreatedFrom  *time.Time
+	CreatedTo    *time.Time
+	Cursor       *types.Cursor
+	Limit        int `validate:"gte=1,lte=100"`
 }
 
 type TransactionRepo struct {
-	db *pgxpool.Pool
+	db    *pgxpool.Pool
+	redis *redis.Client
 }
 
-func NewTransactionRepository(db *pgxpool.Pool) *TransactionRepo {
+func NewTransactionRepository(db *pgxpool.Pool, redis *redis.Client) *TransactionRepo {
 	return &TransactionRepo{
-		db: db,
+		db:    db,
+		redis: redis,
 	}
 }
 
-func (tr *TransactionRepo) PaymentIntent(ctx context.Context, request *types.InitializePaymentRequest, idempotencyKey, correlationID string) (string, error) {
+func (tr *TransactionRepo) PaymentIntent(ctx context.Context, request *types.InitializePaymentRequest, idem *model.IdempotencyKey, correlationID string) (string, error) {
 	tx, err := tr.db.Begin(ctx)
 	if err != nil {
 		return "", err
@@ -37,7 +117,7 @@ func (tr *TransactionRepo) PaymentIntent(ctx context.Context, request *types.Ini
 	var transactionID string
 	err = tx.QueryRow(ctx, transactionQuery,
 		request.Metadata.UserID,
-		idempotencyKey,
+		idem.Key,
 		request.Amount,
 		request.Currency,
 		request.Status,
@@ -48,19 +128,505 @@ func (tr *TransactionRepo) PaymentIntent(ctx context.Context, request *types.Ini
 		return "", err
 	}
 
-	payload, err := json.Marshal(request)
+	if err := idempotency.Insert(ctx, tx, idem); err != nil {
+		tx.Rollback(ctx)
+		return "", err
+	}
+
+	err = outbox.Write(ctx, tx, outbox.Event{
+		Type:          PaymentIntentEvent,
+		Source:        events.SourceTransactions,
+		PartitionKey:  request.Metadata.UserID,
+		CorrelationID: correlationID,
+		Data:          request,
+	})
 	if err != nil {
 		tx.Rollback(ctx)
 		return "", err
 	}
+	return transactionID, tx.Commit(ctx)
 
-	outboxQuery := `INSERT INTO transaction_outbox (event_type,payload, partition_key,status, correlation_id) VALUES ($1, $2, $3, $4, $5)`
+}
 
-	_, err = tx.Exec(ctx, outboxQuery, PaymentIntentEvent, payload, request.Metadata.UserID, "pending", correlationID)
+// FailPaymentIntent fails a payment intent Paystack could not initialize and
+// forgets its idempotency key, so the client can retry with the same key.
+func (tr *TransactionRepo) FailPaymentIntent(ctx context.Context, transactionID string, idem *model.IdempotencyKey, reason string) error {
+	tx, err := tr.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `UPDATE transactions SET status = 'failed', failure_reason = $1, updated_at = NOW() WHERE id = $2 AND status = 'pending'`,
+		reason, transactionID)
+	if err != nil {
+		return err
+	}
+	if err := idempotency.Forget(ctx, tx, idem); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Payout reserves the requested amount on the seller wallet by moving it from
+// balance to locked_balance, records a pending payout transaction and queues
+// the transfer for the payout worker, all in a single database transaction.
+func (tr *TransactionRepo) Payout(ctx context.Context, request *types.PayoutRequest, idem *model.IdempotencyKey, correlationID string) (string, error) {
+	tx, err := tr.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var walletID string
+	err = tx.QueryRow(ctx, `
+		UPDATE wallets
+		SET balance = balance - $1,
+			locked_balance = locked_balance + $1,
+			updated_at = NOW()
+		WHERE user_id = $2 AND currency = $3 AND type = 'settlement' AND balance >= $1
+		RETURNING id`,
+		request.Amount, request.UserID, request.Currency,
+	).Scan(&walletID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrInsufficientBalance
+	}
+	if err != nil {
+		return "", err
+	}
+
+	transactionQuery := `INSERT INTO transactions (user_id, idempotency_key, amount, currency, status, type) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+
+	var transactionID string
+	err = tx.QueryRow(ctx, transactionQuery,
+		request.UserID,
+		idem.Key,
+		request.Amount,
+		request.Currency,
+		"pending",
+		"payout",
+	).Scan(&transactionID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := idempotency.Insert(ctx, tx, idem); err != nil {
+		return "", err
+	}
+
+	err = outbox.Write(ctx, tx, outbox.Event{
+		Type:          kafka.EventPayoutRequested,
+		Source:        events.SourceTransactions,
+		PartitionKey:  request.UserID,
+		CorrelationID: correlationID,
+		Data: types.PayoutEvent{
+			TransactionID: transactionID,
+			UserID:        request.UserID,
+			WalletID:      walletID,
+			Amount:        request.Amount,
+			Currency:      request.Currency,
+			Recipient:     request.Recipient,
+			Reason:        request.Reason,
+		},
+	})
 	if err != nil {
-		tx.Rollback(ctx)
 		return "", err
 	}
 	return transactionID, tx.Commit(ctx)
+}
+
+// CreateRefund validates a refund against the original charge and reserves the
+// seller's share of it. The charge row is locked so concurrent refunds cannot
+// together exceed the charged amount.
+func (tr *TransactionRepo) CreateRefund(ctx context.Context, transactionID string, request *types.RefundRequest, idem *model.IdempotencyKey) (*Refund, error) {
+	tx, err := tr.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	refund := &Refund{TransactionID: transactionID}
+	var status, txType string
+	err = tx.QueryRow(ctx, `
+		SELECT user_id, amount, currency, COALESCE(psp_reference, ''), status, type
+		FROM transactions
+		WHERE id = $1
+		FOR UPDATE`, transactionID,
+	).Scan(&refund.UserID, &refund.ChargeAmount, &refund.Currency, &refund.PspReference, &status, &txType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if txType != "payment_intent" || (status != "completed" && status != "refunded") {
+		return nil, ErrNotRefundable
+	}
+	if refund.Currency != constants.SystemCurrency {
+		return nil, ErrRefundCurrency
+	}
+
+	// Pending refunds count against the charge as well, otherwise two
+	// in-flight refunds could both pass the check.
+	var refunded int64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM transactions
+		WHERE parent_transaction_id = $1 AND type = 'refund' AND status IN ('pending', 'completed')`,
+		transactionID,
+	).Scan(&refunded)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := refund.ChargeAmount - refunded
+	refund.Amount = request.Amount
+	if refund.Amount == 0 {
+		refund.Amount = remaining
+	}
+	if remaining <= 0 || refund.Amount > remaining {
+		return nil, ErrRefundExceedsCharge
+	}
+	refund.RefundedTotal = refunded + refund.Amount
+
+	// Split the refund in the same proportion as the original fee split.
+	var platformCredit int64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(credit), 0)
+		FROM ledger_entries
+		WHERE transaction_id = $1 AND account_id = $2`,
+		transactionID, constants.AccountPlatformID,
+	).Scan(&platformCredit)
+	if err != nil {
+		return nil, err
+	}
+	refund.split(platformCredit)
+
+	err = tx.QueryRow(ctx, `
+		UPDATE wallets
+		SET balance = balance - $1,
+			locked_balance = locked_balance + $1,
+			updated_at = NOW()
+		WHERE user_id = $2 AND currency = $3 AND type = 'settlement' AND balance >= $1
+		RETURNING id`,
+		refund.SellerAmount, refund.UserID, refund.Currency,
+	).Scan(&refund.WalletID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInsufficientBalance
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	refund.Status = "pending"
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transactions (user_id, idempotency_key, amount, currency, status, type, parent_transaction_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		refund.UserID, idem.Key, refund.Amount, refund.Currency, refund.Status, "refund", transactionID,
+	).Scan(&refund.ID, &refund.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := idempotency.Insert(ctx, tx, idem); err != nil {
+		return nil, err
+	}
+
+	return refund, tx.Commit(ctx)
+}
+
+// GetRefund loads a refund with its seller and platform shares, computed the
+// way CreateRefund computed them when it reserved the seller's share.
+func (tr *TransactionRepo) GetRefund(ctx context.Context, refundID string) (*Refund, error) {
+	refund := &Refund{ID: refundID}
+	var platformCredit int64
+	err := tr.db.QueryRow(ctx, `
+		SELECT r.parent_transaction_id, r.user_id, w.id, COALESCE(c.psp_reference, ''), COALESCE(r.psp_reference, ''),
+			r.status, r.currency, r.amount, c.amount, r.created_at,
+			(SELECT COALESCE(SUM(credit), 0) FROM ledger_entries WHERE transaction_id = c.id AND account_id = $2)
+		FROM transactions r
+		JOIN transactions c ON c.id = r.parent_transaction_id
+		JOIN wallets w ON w.user_id = r.user_id AND w.currency = r.currency AND w.type = 'settlement'
+		WHERE r.id = $1 AND r.type = 'refund'`,
+		refundID, constants.AccountPlatformID,
+	).Scan(&refund.TransactionID, &refund.UserID, &refund.WalletID, &refund.PspReference, &refund.PspRefundID,
+		&refund.Status, &refund.Currency, &refund.Amount, &refund.ChargeAmount, &refund.CreatedAt, &platformCredit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	refund.split(platformCredit)
+	return refund, nil
+}
+
+// SetRefundReference records Paystack's ID for a pending refund. A refund that
+// is already linked keeps its ID.
+func (tr *TransactionRepo) SetRefundReference(ctx context.Context, refundID, pspRefundID string) error {
+	_, err := tr.db.Exec(ctx, `UPDATE transactions SET psp_reference = $1, updated_at = NOW() WHERE id = $2 AND type = 'refund' AND status = 'pending' AND psp_reference IS NULL`,
+		pspRefundID, refundID)
+	return err
+}
+
+// ListPendingRefunds returns the oldest refunds still pending that were last
+// updated before updatedBefore.
+func (tr *TransactionRepo) ListPendingRefunds(ctx context.Context, updatedBefore time.Time, limit int) ([]string, error) {
+	return tr.listIDs(ctx, `
+		SELECT id::text FROM transactions
+		WHERE type = 'refund' AND status = 'pending' AND updated_at < $1
+		ORDER BY updated_at
+		LIMIT $2`, updatedBefore, limit)
+}
+
+// ListPendingRefundsForCharge returns the pending refunds of the charge with
+// the given Paystack reference.
+func (tr *TransactionRepo) ListPendingRefundsForCharge(ctx context.Context, chargeReference string) ([]string, error) {
+	return tr.listIDs(ctx, `
+		SELECT r.id::text FROM transactions r
+		JOIN transactions c ON c.id = r.parent_transaction_id
+		WHERE c.psp_reference = $1 AND r.type = 'refund' AND r.status = 'pending'
+		ORDER BY r.created_at`, chargeReference)
+}
+
+// LinkedRefundReferences returns the Paystack refund IDs already recorded
+// against the charge's refunds.
+func (tr *TransactionRepo) LinkedRefundReferences(ctx context.Context, chargeID string) ([]string, error) {
+	return tr.listIDs(ctx, `
+		SELECT psp_reference FROM transactions
+		WHERE parent_transaction_id = $1 AND type = 'refund' AND psp_reference IS NOT NULL`, chargeID)
+}
+
+func (tr *TransactionRepo) listIDs(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := tr.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CompleteRefund settles a refund confirmed by the PSP. The seller and platform
+// are debited their shares and the external account is credited the full amount.
+func (tr *TransactionRepo) CompleteRefund(ctx context.Context, refund *Refund, pspReference string) error {
+	lock, err := wallet.Lock(ctx, tr.db, tr.redis, refund.UserID)
+	if err != nil {
+		return err
+	}
+	defer lock.Release(ctx)
+
+	tx, err := tr.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	res, err := tx.Exec(ctx, `UPDATE transactions SET psp_reference = $1, status = 'completed', updated_at = NOW() WHERE id = $2 AND status = 'pending'`,
+		pspReference, refund.ID)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return nil
+	}
+
+	var sellerBalanceAfter int64
+	err = tx.QueryRow(ctx, "UPDATE wallets SET locked_balance = locked_balance - $1, fence_token = $3, updated_at = NOW() WHERE id = $2 AND locked_balance >= $1 RETURNING locked_balance",
+		refund.SellerAmount, refund.WalletID, lock.Token()).Scan(&sellerBalanceAfter)
+	if err != nil {
+		return wallet.FenceError(err)
+	}
+	if err := insertLedgerEntry(ctx, tx, refund.ID, refund.WalletID, refund.SellerAmount, 0, sellerBalanceAfter, "refund"); err != nil {
+		return err
+	}
+
+	if refund.PlatformAmount > 0 {
+		var platformBalanceAfter int64
+		err = tx.QueryRow(ctx, "UPDATE wallets SET balance = balance - $1, updated_at = NOW() WHERE id = $2 RETURNING balance",
+			refund.PlatformAmount, constants.AccountPlatformID).Scan(&platformBalanceAfter)
+		if err != nil {
+			return err
+		}
+		if err := insertLedgerEntry(ctx, tx, refund.ID, string(constants.AccountPlatformID), refund.PlatformAmount, 0, platformBalanceAfter, "refund"); err != nil {
+			return err
+		}
+	}
+
+	var externalBalanceAfter int64
+	err = tx.QueryRow(ctx, "UPDATE wallets SET balance = balance - $1, updated_at = NOW() WHERE id = $2 RETURNING balance",
+		refund.Amount, constants.AccountExternalID).Scan(&externalBalanceAfter)
+	if err != nil {
+		return err
+	}
+	if err := insertLedgerEntry(ctx, tx, refund.ID, string(constants.AccountExternalID), 0, refund.Amount, externalBalanceAfter, "refund"); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE transactions SET status = 'refunded', updated_at = NOW()
+		WHERE id = $1 AND amount = (
+			SELECT COALESCE(SUM(amount), 0) FROM transactions
+			WHERE parent_transaction_id = $1 AND type = 'refund' AND status = 'completed'
+		)`, refund.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FailRefund marks a refund as failed and releases the seller's reserved share.
+func (tr *TransactionRepo) FailRefund(ctx context.Context, refund *Refund, reason string) error {
+	lock, err := wallet.Lock(ctx, tr.db, tr.redis, refund.UserID)
+	if err != nil {
+		return err
+	}
+	defer lock.Release(ctx)
+
+	tx, err := tr.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	res, err := tx.Exec(ctx, `UPDATE transactions SET status = 'failed', failure_reason = $1, updated_at = NOW() WHERE id = $2 AND status = 'pending'`,
+		reason, refund.ID)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE wallets
+		SET locked_balance = locked_balance - $1,
+			balance = balance + $1,
+			fence_token = $3,
+			updated_at = NOW()
+		WHERE id = $2 AND locked_balance >= $1`,
+		refund.SellerAmount, refund.WalletID, lock.Token())
+	if err != nil {
+		return wallet.FenceError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+const transactionColumns = `id, idempotency_key, user_id, amount, currency, COALESCE(psp_reference, ''), status, type, COALESCE(failure_reason, ''), parent_transaction_id, created_at, updated_at`
+
+func scanTransaction(row pgx.Row, t *model.Transaction) error {
+	return row.Scan(&t.ID, &t.IdempotencyKey, &t.UserID, &t.Amount, &t.Currency, &t.PspReference, &t.Status, &t.Type, &t.FailureReason, &t.ParentTransactionID, &t.CreatedAt, &t.UpdatedAt)
+}
+
+func (tr *TransactionRepo) GetTransaction(ctx context.Context, id string) (*model.Transaction, error) {
+	var t model.Transaction
+	err := scanTransaction(tr.db.QueryRow(ctx, "SELECT "+transactionColumns+" FROM transactions WHERE id = $1", id), &t)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (tr *TransactionRepo) GetLedgerEntries(ctx context.Context, transactionID string) ([]model.LedgerEntry, error) {
+	rows, err := tr.db.Query(ctx, `
+		SELECT id, transaction_id, account_id, debit, credit, balance_after, description, created_at, updated_at
+		FROM ledger_entries
+		WHERE transaction_id = $1
+		ORDER BY id ASC`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.LedgerEntry{}
+	for rows.Next() {
+		var e model.LedgerEntry
+		if err := rows.Scan(&e.ID, &e.TransactionID, &e.AccountID, &e.Debit, &e.Credit, &e.BalanceAfter, &e.Description, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// ListTransactions returns up to filter.Limit transactions matching the filter.
+// The ORDER BY matches idx_transactions_created_at so the cursor predicate is
+// answered from the index instead of an offset scan.
+func (tr *TransactionRepo) ListTransactions(ctx context.Context, filter *ListFilter) ([]model.Transaction, error) {
+	var conditions []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.UserID != "" {
+		add("user_id = $%d", filter.UserID)
+	}
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+	if filter.Type != "" {
+		add("type = $%d", filter.Type)
+	}
+	if filter.Currency != "" {
+		add("currency = $%d", filter.Currency)
+	}
+	if filter.PspReference != "" {
+		add("psp_reference = $%d", filter.PspReference)
+	}
+	if filter.CreatedFrom != nil {
+		add("created_at >= $%d", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		add("created_at < $%d", *filter.CreatedTo)
+	}
+	if filter.Cursor != nil {
+		args = append(args, filter.Cursor.CreatedAt, filter.Cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	query := "SELECT " + transactionColumns + " FROM transactions"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := tr.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []model.Transaction{}
+	for rows.Next() {
+		var t model.Transaction
+		if err := scanTransaction(rows, &t); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
 
+func insertLedgerEntry(ctx context.Context, tx pgx.Tx, transactionID, accountID string, debit, credit, balanceAfter int64, description string) error {
+	_, err := tx.Exec(ctx, "INSERT INTO ledger_entries (transaction_id,account_id,debit,credit,balance_after,description) VALUES ($1, $2, $3, $4, $5, $6)",
+		transactionID, accountID, debit, credit, balanceAfter, description)
+	return err
 }
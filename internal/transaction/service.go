@@ -2,95 +2,191 @@ package transaction
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"time"
+	"slices"
+	"strconv"
 
 	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/internal/model"
 	"github.com/Niiaks/Aegis/internal/psp"
-	"github.com/Niiaks/Aegis/internal/redis"
+	"github.com/Niiaks/Aegis/pkg/constants"
 	"github.com/Niiaks/Aegis/pkg/types"
 )
 
+type TransactionDetail struct {
+	model.Transaction
+	LedgerEntries []model.LedgerEntry `json:"ledger_entries"`
+}
+
+type TransactionPage struct {
+	Data       []model.Transaction `json:"data"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
 type TransactionService struct {
 	repo           TransactionRepository
-	redis          *redis.Client
 	paystackClient *psp.PaystackClient
 }
 
-func NewTransactionService(repo TransactionRepository, redis *redis.Client, paystackClient *psp.PaystackClient) *TransactionService {
+func NewTransactionService(repo TransactionRepository, paystackClient *psp.PaystackClient) *TransactionService {
 	return &TransactionService{
 		repo:           repo,
-		redis:          redis,
 		paystackClient: paystackClient,
 	}
 }
 
-func (ts *TransactionService) PaymentIntent(ctx context.Context, request *types.InitializePaymentRequest, idempotencyKey, requestID string) (*types.InitializePaymentResponse, error) {
+func (ts *TransactionService) PaymentIntent(ctx context.Context, request *types.InitializePaymentRequest, idem *model.IdempotencyKey, requestID string) (*types.InitializePaymentResponse, error) {
 	logger := middleware.GetLogger(ctx)
 
 	logger.Info().Msg("Creating payment intent in service layer")
 
-	//check idempotency
-	cached, err := ts.redis.CheckAndSetIdempotency(ctx, idempotencyKey, 24*time.Hour)
-
-	if cached != nil {
-		logger.Info().Msg("Returning cached payment intent response due to idempotency key")
-		var res types.InitializePaymentResponse
-		json.Unmarshal(cached, &res)
-		return &res, nil
-	}
-
-	if errors.Is(err, redis.ErrKeyExists) {
-		logger.Warn().Msg("Request still in progress with same idempotency key")
-		return nil, fmt.Errorf("request in progress: please retry later")
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
 	if !validateCurrency(request.Currency) {
 		logger.Error().Msg("Unsupported currency")
-		ts.redis.MarkIdempotencyFailed(ctx, idempotencyKey)
 		return nil, fmt.Errorf("unsupported currency")
 	}
 	// check if amount is positive
 	if request.Amount <= 0 {
 		logger.Error().Msg("Amount must be more than zero")
-		ts.redis.MarkIdempotencyFailed(ctx, idempotencyKey)
 		return nil, fmt.Errorf("amount must be more than zero")
 	}
 	// additional checks can be added here
 
 	// Call Paystack to initialize payment
-	transactionID, err := ts.repo.PaymentIntent(ctx, request, idempotencyKey, requestID)
+	transactionID, err := ts.repo.PaymentIntent(ctx, request, idem, requestID)
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to create payment intent in repository layer")
-		ts.redis.MarkIdempotencyFailed(ctx, idempotencyKey)
 		return nil, fmt.Errorf("failed to create payment intent: %w", err)
 	}
 
 	paystackRes, err := ts.paystackClient.InitializePayment(ctx, request, transactionID)
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to initialize payment with Paystack")
-		ts.redis.MarkIdempotencyFailed(ctx, idempotencyKey)
+		// The client never got an authorization URL, so nothing can be paid
+		// against this intent: fail it and free the key for a retry.
+		if failErr := ts.repo.FailPaymentIntent(ctx, transactionID, idem, err.Error()); failErr != nil {
+			logger.Error().Err(failErr).Str("transaction_id", transactionID).Msg("Failed to fail payment intent")
+		}
 		return nil, fmt.Errorf("failed to initialize payment: %w", err)
 	}
 
-	// Cache the successful response for future duplicate requests
-	responseBytes, _ := json.Marshal(paystackRes)
-	ts.redis.MarkIdempotencyComplete(ctx, idempotencyKey, responseBytes, 24*time.Hour)
-
 	return paystackRes, nil
 }
 
 func validateCurrency(currency string) bool {
-	supportedCurrencies := map[string]bool{
-		"USD": true,
-		"EUR": true,
-		"GHS": true,
+	return slices.Contains(constants.SupportedCurrencies, currency)
+}
+
+func (ts *TransactionService) Payout(ctx context.Context, request *types.PayoutRequest, idem *model.IdempotencyKey, requestID string) (*types.PayoutResponse, error) {
+	logger := middleware.GetLogger(ctx)
+
+	logger.Info().Msg("Creating payout in service layer")
+
+	if !validateCurrency(request.Currency) {
+		logger.Error().Msg("Unsupported currency")
+		return nil, fmt.Errorf("unsupported currency")
+	}
+
+	transactionID, err := ts.repo.Payout(ctx, request, idem, requestID)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to create payout in repository layer")
+		return nil, fmt.Errorf("failed to create payout: %w", err)
+	}
+
+	res := &types.PayoutResponse{
+		TransactionID: transactionID,
+		Status:        "pending",
+		Amount:        request.Amount,
+		Currency:      request.Currency,
+	}
+
+	return res, nil
+}
+
+func (ts *TransactionService) Refund(ctx context.Context, transactionID string, request *types.RefundRequest, idem *model.IdempotencyKey) (*types.RefundResponse, error) {
+	logger := middleware.GetLogger(ctx)
+
+	logger.Info().Str("transaction_id", transactionID).Msg("Creating refund in service layer")
+
+	refund, err := ts.repo.CreateRefund(ctx, transactionID, request, idem)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to create refund in repository layer")
+		return nil, fmt.Errorf("failed to create refund: %w", err)
+	}
+
+	pspRes, err := ts.paystackClient.CreateRefund(ctx, &types.PaystackRefundRequest{
+		Transaction:  refund.PspReference,
+		Amount:       refund.Amount,
+		Currency:     refund.Currency,
+		MerchantNote: request.Reason,
+	})
+	if errors.Is(err, psp.ErrRequestRejected) {
+		logger.Error().Err(err).Str("refund_id", refund.ID).Msg("Paystack rejected refund")
+		if failErr := ts.repo.FailRefund(ctx, refund, err.Error()); failErr != nil {
+			logger.Error().Err(failErr).Str("refund_id", refund.ID).Msg("Failed to release refund reservation")
+		}
+		return nil, fmt.Errorf("failed to refund payment: %w", err)
+	}
+
+	res := &types.RefundResponse{
+		RefundID:      refund.ID,
+		TransactionID: refund.TransactionID,
+		Amount:        refund.Amount,
+		Currency:      refund.Currency,
+		Status:        refund.Status,
+		RefundedTotal: refund.RefundedTotal,
+	}
+	if err != nil {
+		// The outcome at Paystack is unknown, so the refund stays pending and
+		// keeps its reservation until the refund verifier finds it at
+		// Paystack or confirms it never arrived.
+		logger.Warn().Err(err).Str("refund_id", refund.ID).Msg("Refund outcome unknown, leaving pending")
+		return res, nil
+	}
+
+	// Paystack refunds are asynchronous: the refund is settled from the
+	// refund webhook or by the verifier, unless Paystack already finished it.
+	refund.PspRefundID = strconv.FormatInt(pspRes.Data.ID, 10)
+	if err := ts.repo.SetRefundReference(ctx, refund.ID, refund.PspRefundID); err != nil {
+		logger.Error().Err(err).Str("refund_id", refund.ID).Msg("Failed to record Paystack refund ID, verifier will link it")
+	}
+	if err := settleRefund(ctx, ts.repo, refund, pspRes.Data.Status); err != nil {
+		logger.Error().Err(err).Str("refund_id", refund.ID).Msg("Failed to settle refund, leaving pending")
+	}
+	res.Status = refund.Status
+
+	return res, nil
+}
+
+func (ts *TransactionService) GetTransaction(ctx context.Context, id string) (*TransactionDetail, error) {
+	t, err := ts.repo.GetTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ts.repo.GetLedgerEntries(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ledger entries: %w", err)
+	}
+
+	return &TransactionDetail{Transaction: *t, LedgerEntries: entries}, nil
+}
+
+func (ts *TransactionService) ListTransactions(ctx context.Context, filter *ListFilter) (*TransactionPage, error) {
+	// Fetch one extra row to know whether another page exists.
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	transactions, err := ts.repo.ListTransactions(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TransactionPage{Data: transactions}
+	if len(transactions) > limit {
+		page.Data = transactions[:limit]
+		last := page.Data[limit-1]
+		page.NextCursor = types.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
 	}
-	return supportedCurrencies[currency]
+	return page, nil
 }
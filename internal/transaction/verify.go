@@ -0,0 +1,196 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/config"
+	"github.com/Niiaks/Aegis/internal/psp"
+	"github.com/Niiaks/Aegis/pkg/types"
+	"github.com/rs/zerolog"
+)
+
+const verifyBatchSize = 100
+
+// Refund statuses reported by Paystack. Pending and processing refunds are
+// still in flight.
+const (
+	RefundProcessed = "processed"
+	RefundFailed    = "failed"
+)
+
+// settleRefund applies the status Paystack reports for a refund: processed
+// posts its ledger lines, failed releases its reservation and anything else
+// leaves it pending. refund.Status is updated to match.
+func settleRefund(ctx context.Context, repo TransactionRepository, refund *Refund, pspStatus string) error {
+	switch pspStatus {
+	case RefundProcessed:
+		if err := repo.CompleteRefund(ctx, refund, refund.PspRefundID); err != nil {
+			return err
+		}
+		refund.Status = "completed"
+	case RefundFailed:
+		if err := repo.FailRefund(ctx, refund, "refund failed at Paystack"); err != nil {
+			return err
+		}
+		refund.Status = "failed"
+	}
+	return nil
+}
+
+// RefundVerifier settles pending refunds by asking Paystack for their status.
+// It runs when a refund webhook arrives and periodically for refunds whose
+// webhook never did.
+type RefundVerifier struct {
+	repo     TransactionRepository
+	paystack psp.Client
+	logger   *zerolog.Logger
+	cfg      config.RefundConfig
+}
+
+func NewRefundVerifier(repo TransactionRepository, paystack psp.Client, logger *zerolog.Logger, cfg config.RefundConfig) *RefundVerifier {
+	return &RefundVerifier{
+		repo:     repo,
+		paystack: paystack,
+		logger:   logger,
+		cfg:      cfg,
+	}
+}
+
+// Start verifies every configured interval until ctx is cancelled.
+func (v *RefundVerifier) Start(ctx context.Context) error {
+	v.logger.Info().Msg("Starting Refund Verifier")
+	ticker := time.NewTicker(v.cfg.VerifyInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			v.logger.Info().Msg("Stopping Refund Verifier")
+			return nil
+		case <-ticker.C:
+		}
+
+		if _, err := v.Run(ctx); err != nil && ctx.Err() == nil {
+			v.logger.Error().Err(err).Msg("Refund verification failed")
+		}
+	}
+}
+
+// Run verifies refunds that have been pending for longer than VerifyAfter.
+func (v *RefundVerifier) Run(ctx context.Context) (int, error) {
+	ids, err := v.repo.ListPendingRefunds(ctx, time.Now().Add(-v.cfg.VerifyAfter), verifyBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	settled := v.verifyAll(ctx, ids)
+	v.logger.Info().Int("checked", len(ids)).Int("settled", settled).Msg("Refund verification complete")
+	return settled, ctx.Err()
+}
+
+// VerifyCharge verifies the pending refunds of the charge with the given
+// Paystack reference, as named by a refund webhook.
+func (v *RefundVerifier) VerifyCharge(ctx context.Context, chargeReference string) error {
+	ids, err := v.repo.ListPendingRefundsForCharge(ctx, chargeReference)
+	if err != nil {
+		return err
+	}
+	v.verifyAll(ctx, ids)
+	return ctx.Err()
+}
+
+func (v *RefundVerifier) verifyAll(ctx context.Context, ids []string) int {
+	settled := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		done, err := v.verify(ctx, id)
+		if err != nil {
+			v.logger.Warn().Err(err).Str("refund_id", id).Msg("Failed to verify refund")
+			continue
+		}
+		if done {
+			settled++
+		}
+	}
+	return settled
+}
+
+// verify settles one refund if Paystack has finished it, and reports whether
+// it did.
+func (v *RefundVerifier) verify(ctx context.Context, refundID string) (bool, error) {
+	refund, err := v.repo.GetRefund(ctx, refundID)
+	if err != nil {
+		return false, err
+	}
+	if refund.Status != "pending" {
+		return false, nil
+	}
+
+	var status string
+	if refund.PspRefundID != "" {
+		res, err := v.paystack.FetchRefund(ctx, refund.PspRefundID)
+		if err != nil {
+			return false, err
+		}
+		status = res.Data.Status
+	} else {
+		found, err := v.findRefund(ctx, refund)
+		if err != nil {
+			return false, err
+		}
+		if found == nil {
+			// The create call never reached Paystack. Give it VerifyAfter
+			// to show up before releasing the reservation.
+			if time.Since(refund.CreatedAt) < v.cfg.VerifyAfter {
+				return false, nil
+			}
+			if err := v.repo.FailRefund(ctx, refund, "refund not found at Paystack"); err != nil {
+				return false, err
+			}
+			v.logger.Info().Str("refund_id", refund.ID).Msg("Refund never reached Paystack, reservation released")
+			return true, nil
+		}
+		refund.PspRefundID = strconv.FormatInt(found.ID, 10)
+		if err := v.repo.SetRefundReference(ctx, refund.ID, refund.PspRefundID); err != nil {
+			return false, err
+		}
+		status = found.Status
+	}
+
+	if err := settleRefund(ctx, v.repo, refund, status); err != nil {
+		return false, err
+	}
+	if refund.Status == "pending" {
+		return false, nil
+	}
+	v.logger.Info().Str("refund_id", refund.ID).Str("status", refund.Status).Msg("Refund settled")
+	return true, nil
+}
+
+// findRefund looks for the Paystack refund behind a refund whose create call
+// had an unknown outcome: one of the charge's refunds at Paystack, for the
+// same amount, that no other refund is linked to.
+func (v *RefundVerifier) findRefund(ctx context.Context, refund *Refund) (*types.PaystackRefund, error) {
+	linked, err := v.repo.LinkedRefundReferences(ctx, refund.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	res, err := v.paystack.ListRefunds(ctx, refund.PspReference)
+	if errors.Is(err, psp.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range res.Data {
+		if r.Amount == refund.Amount && !slices.Contains(linked, strconv.FormatInt(r.ID, 10)) {
+			return &res.Data[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -1,6 +1,15 @@
 package user
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/internal/model"
+	"github.com/Niiaks/Aegis/pkg/types"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -15,3 +24,148 @@ func NewUserHandler(service *UserService) *UserHandler {
 }
 
 var validate = validator.New()
+
+func (uh *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+	logger.Info().Msg("Received request to create user")
+
+	var user model.User
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode create user request")
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := validate.Struct(&user); err != nil {
+		logger.Error().Err(err).Msg("Validation error on create user request")
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := uh.service.CreateUser(ctx, &user)
+	switch {
+	case errors.Is(err, ErrEmailTaken):
+		http.Error(w, ErrEmailTaken.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrPlatformPspTaken):
+		http.Error(w, ErrPlatformPspTaken.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		logger.Error().Err(err).Msg("Failed to create user")
+		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(res)
+	logger.Info().Str("user_id", res.ID.String()).Int("wallets", len(res.Wallets)).Msg("User created successfully")
+}
+
+func (uh *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	userID := chi.URLParam(r, "id")
+	if err := validate.Var(userID, "required,uuid"); err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := uh.service.GetUser(ctx, userID)
+	if errors.Is(err, ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("user_id", userID).Msg("Failed to get user")
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (uh *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	q := r.URL.Query()
+	filter := ListFilter{
+		PlatformID: q.Get("platform_id"),
+		Limit:      50,
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := types.DecodeCursor(v)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		filter.Cursor = cursor
+	}
+
+	if err := validate.Struct(&filter); err != nil {
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := uh.service.ListUsers(ctx, &filter)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list users")
+		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (uh *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	userID := chi.URLParam(r, "id")
+	if err := validate.Var(userID, "required,uuid"); err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode update user request")
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := validate.Struct(&req); err != nil {
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := uh.service.UpdateUser(ctx, userID, &req)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrEmailTaken):
+		http.Error(w, ErrEmailTaken.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		logger.Error().Err(err).Str("user_id", userID).Msg("Failed to update user")
+		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
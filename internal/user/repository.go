@@ -2,15 +2,41 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/Niiaks/Aegis/internal/model"
+	"github.com/Niiaks/Aegis/pkg/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrEmailTaken       = errors.New("a user with this email already exists")
+	ErrPlatformPspTaken = errors.New("a user with this platform_id and psp_id already exists")
+)
+
 type UserRepository interface {
-	CreateUser(ctx context.Context, user *model.User) error
+	CreateUser(ctx context.Context, user *model.User, currencies []string) ([]model.Wallet, error)
+	GetUser(ctx context.Context, id string) (*model.User, error)
+	ListUsers(ctx context.Context, filter *ListFilter) ([]model.User, error)
+	UpdateUser(ctx context.Context, id string, req *UpdateUserRequest) (*model.User, error)
+}
+
+// ListFilter narrows a user listing. Results are ordered newest first and
+// paged with a keyset cursor on (created_at, id).
+type ListFilter struct {
+	PlatformID string
+	Cursor     *types.Cursor
+	Limit      int `validate:"gte=1,lte=100"`
+}
+
+type UpdateUserRequest struct {
+	Name  *string `json:"name" validate:"omitempty,min=2,max=100"`
+	Email *string `json:"email" validate:"omitempty,email"`
 }
 
 type UserRepo struct {
@@ -21,11 +47,116 @@ func NewUserRepository(db *pgxpool.Pool) *UserRepo {
 	return &UserRepo{db: db}
 }
 
-func (ur *UserRepo) CreateUser(ctx context.Context, user *model.User) error {
-	err := ur.db.QueryRow(ctx, "INSERT INTO users (name, email, platform_id, psp_id, created_at, updated_at) VALUES ($1, $2,$3,$4,$5,$6) RETURNING id", user.Name, user.Email, user.PlatformID, user.PspID, time.Now(), time.Now()).Scan(&user.ID)
+// CreateUser inserts the user and a settlement wallet per currency in one
+// transaction, so a seller can never exist without somewhere to be credited.
+func (ur *UserRepo) CreateUser(ctx context.Context, user *model.User, currencies []string) ([]model.Wallet, error) {
+	tx, err := ur.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
 
+	err = tx.QueryRow(ctx, "INSERT INTO users (name, email, platform_id, psp_id, created_at, updated_at) VALUES ($1, $2,$3,$4,$5,$6) RETURNING id, created_at, updated_at", user.Name, user.Email, user.PlatformID, user.PspID, time.Now(), time.Now()).
+		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to create user: %w", err)
+		return nil, fmt.Errorf("failed to create user: %w", mapConstraintError(err))
+	}
+
+	wallets := make([]model.Wallet, 0, len(currencies))
+	for _, currency := range currencies {
+		wallet := model.Wallet{UserID: user.ID, Currency: currency, Type: "settlement"}
+		err = tx.QueryRow(ctx, "INSERT INTO wallets (user_id, currency, type) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at", wallet.UserID, wallet.Currency, wallet.Type).
+			Scan(&wallet.ID, &wallet.CreatedAt, &wallet.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to provision %s wallet: %w", currency, err)
+		}
+		wallets = append(wallets, wallet)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+const userColumns = "id, platform_id, psp_id, name, email, created_at, updated_at"
+
+func scanUser(row pgx.Row, u *model.User) error {
+	return row.Scan(&u.ID, &u.PlatformID, &u.PspID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt)
+}
+
+func (ur *UserRepo) GetUser(ctx context.Context, id string) (*model.User, error) {
+	var u model.User
+	err := scanUser(ur.db.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id), &u)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (ur *UserRepo) ListUsers(ctx context.Context, filter *ListFilter) ([]model.User, error) {
+	args := []any{filter.PlatformID, filter.Limit}
+	cursorCondition := ""
+	if filter.Cursor != nil {
+		args = append(args, filter.Cursor.CreatedAt, filter.Cursor.ID)
+		cursorCondition = "AND (created_at, id) < ($3, $4)"
+	}
+
+	rows, err := ur.db.Query(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE ($1 = '' OR platform_id = $1) `+cursorCondition+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []model.User{}
+	for rows.Next() {
+		var u model.User
+		if err := scanUser(rows, &u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (ur *UserRepo) UpdateUser(ctx context.Context, id string, req *UpdateUserRequest) (*model.User, error) {
+	var u model.User
+	err := scanUser(ur.db.QueryRow(ctx, `
+		UPDATE users
+		SET name = COALESCE($2, name),
+			email = COALESCE($3, email),
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+userColumns, id, req.Name, req.Email), &u)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", mapConstraintError(err))
+	}
+	return &u, nil
+}
+
+// mapConstraintError turns unique violations on the users table into errors
+// the handler can report as conflicts.
+func mapConstraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return err
+	}
+	switch pgErr.ConstraintName {
+	case "users_email_unique":
+		return ErrEmailTaken
+	case "users_platform_psp_unique":
+		return ErrPlatformPspTaken
 	}
-	return nil
+	return err
 }
@@ -3,9 +3,22 @@ package user
 import (
 	"context"
 
+	"github.com/Niiaks/Aegis/internal/middleware"
 	"github.com/Niiaks/Aegis/internal/model"
+	"github.com/Niiaks/Aegis/pkg/constants"
+	"github.com/Niiaks/Aegis/pkg/types"
 )
 
+type UserWithWallets struct {
+	model.User
+	Wallets []model.Wallet `json:"wallets"`
+}
+
+type UserPage struct {
+	Data       []model.User `json:"data"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
 type UserService struct {
 	repo UserRepository
 }
@@ -16,6 +29,42 @@ func NewUserService(repo UserRepository) *UserService {
 	}
 }
 
-func (us *UserService) CreateUser(ctx context.Context, user *model.User) error {
-	return us.repo.CreateUser(ctx, user)
+// CreateUser onboards a seller and provisions a settlement wallet for every
+// supported currency.
+func (us *UserService) CreateUser(ctx context.Context, user *model.User) (*UserWithWallets, error) {
+	logger := middleware.GetLogger(ctx)
+	logger.Info().Msg("Creating user in service layer")
+
+	wallets, err := us.repo.CreateUser(ctx, user, constants.SupportedCurrencies)
+	if err != nil {
+		return nil, err
+	}
+	return &UserWithWallets{User: *user, Wallets: wallets}, nil
+}
+
+func (us *UserService) GetUser(ctx context.Context, id string) (*model.User, error) {
+	return us.repo.GetUser(ctx, id)
+}
+
+func (us *UserService) ListUsers(ctx context.Context, filter *ListFilter) (*UserPage, error) {
+	// Fetch one extra row to know whether another page exists.
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	users, err := us.repo.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &UserPage{Data: users}
+	if len(users) > limit {
+		page.Data = users[:limit]
+		last := page.Data[limit-1]
+		page.NextCursor = types.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return page, nil
+}
+
+func (us *UserService) UpdateUser(ctx context.Context, id string, req *UpdateUserRequest) (*model.User, error) {
+	return us.repo.UpdateUser(ctx, id, req)
 }
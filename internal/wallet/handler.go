@@ -1,6 +1,15 @@
 package wallet
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/internal/model"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -15,3 +24,165 @@ func NewWalletHandler(service *WalletService) *WalletHandler {
 }
 
 var validate = validator.New()
+
+func (wh *WalletHandler) CreateWallet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	var wallet model.Wallet
+	if err := json.NewDecoder(r.Body).Decode(&wallet); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode create wallet request")
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := validate.Struct(&wallet); err != nil {
+		logger.Error().Err(err).Msg("Validation error on create wallet request")
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := wh.Service.CreateWallet(ctx, &wallet)
+	switch {
+	case errors.Is(err, ErrWalletExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		logger.Error().Err(err).Msg("Failed to create wallet")
+		http.Error(w, "Failed to create wallet", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(wallet)
+	logger.Info().Str("wallet_id", wallet.ID.String()).Msg("Wallet created successfully")
+}
+
+func (wh *WalletHandler) GetWallet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	walletID := chi.URLParam(r, "id")
+	if err := validate.Var(walletID, "required,uuid"); err != nil {
+		http.Error(w, "Invalid wallet id", http.StatusBadRequest)
+		return
+	}
+
+	wallet, err := wh.Service.GetWallet(ctx, walletID)
+	if errors.Is(err, ErrWalletNotFound) {
+		http.Error(w, "Wallet not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("wallet_id", walletID).Msg("Failed to get wallet")
+		http.Error(w, "Failed to get wallet", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wallet)
+}
+
+func (wh *WalletHandler) ListWallets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	userID := r.URL.Query().Get("user_id")
+	currency := r.URL.Query().Get("currency")
+	if err := validate.Var(userID, "required,uuid"); err != nil {
+		http.Error(w, "user_id query parameter must be a valid uuid", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Var(currency, "omitempty,len=3"); err != nil {
+		http.Error(w, "currency must be a 3 letter code", http.StatusBadRequest)
+		return
+	}
+
+	wallets, err := wh.Service.ListWallets(ctx, userID, currency)
+	if err != nil {
+		logger.Error().Err(err).Str("user_id", userID).Msg("Failed to list wallets")
+		http.Error(w, "Failed to list wallets", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": wallets})
+}
+
+func (wh *WalletHandler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := middleware.GetLogger(ctx)
+
+	walletID := chi.URLParam(r, "id")
+	if err := validate.Var(walletID, "required,uuid"); err != nil {
+		http.Error(w, "Invalid wallet id", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := StatementFilter{
+		To:    time.Now().UTC(),
+		Limit: 100,
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid to: expected RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		filter.To = t
+	}
+	// Default to the 30 days leading up to the end of the range
+	filter.From = filter.To.AddDate(0, 0, -30)
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid from: expected RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		filter.From = t
+	}
+	if !filter.From.Before(filter.To) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := q.Get("cursor"); v != "" {
+		afterID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || afterID < 0 {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		filter.AfterID = afterID
+	}
+
+	if err := validate.Struct(&filter); err != nil {
+		http.Error(w, "Validation error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	statement, err := wh.Service.GetStatement(ctx, walletID, &filter)
+	if errors.Is(err, ErrWalletNotFound) {
+		http.Error(w, "Wallet not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("wallet_id", walletID).Msg("Failed to build wallet statement")
+		http.Error(w, "Failed to build wallet statement", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statement)
+}
@@ -2,13 +2,54 @@ package wallet
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/Niiaks/Aegis/internal/model"
+	"github.com/Niiaks/Aegis/internal/redis"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var (
+	ErrWalletNotFound = errors.New("wallet not found")
+	ErrWalletExists   = errors.New("wallet already exists for user, currency and type")
+	ErrUserNotFound   = errors.New("user not found")
+	// ErrStaleFencingToken means a wallet write carried the fencing token of a
+	// Redis lock that has since passed to another holder.
+	ErrStaleFencingToken = errors.New("stale wallet fencing token")
+)
+
+// codeStaleFencingToken is raised by the wallets fence_token trigger.
+const codeStaleFencingToken = "AG001"
+
 type WalletRepository interface {
 	CreateWallet(ctx context.Context, wallet *model.Wallet) error
+	GetWallet(ctx context.Context, id string) (*model.Wallet, error)
+	ListWallets(ctx context.Context, userID, currency string) ([]model.Wallet, error)
+	GetStatement(ctx context.Context, wallet *model.Wallet, filter *StatementFilter) (*Statement, error)
+}
+
+// StatementFilter selects the ledger entries of a wallet between From
+// (inclusive) and To (exclusive). AfterID pages through entries in id order.
+type StatementFilter struct {
+	From    time.Time
+	To      time.Time
+	AfterID int64
+	Limit   int `validate:"gte=1,lte=500"`
+}
+
+type Statement struct {
+	WalletID       string              `json:"wallet_id"`
+	Currency       string              `json:"currency"`
+	From           time.Time           `json:"from"`
+	To             time.Time           `json:"to"`
+	OpeningBalance int64               `json:"opening_balance"`
+	ClosingBalance int64               `json:"closing_balance"`
+	Entries        []model.LedgerEntry `json:"entries"`
+	NextCursor     string              `json:"next_cursor,omitempty"`
 }
 
 type WalletRepo struct {
@@ -20,9 +61,139 @@ func NewWalletRepository(db *pgxpool.Pool) *WalletRepo {
 }
 
 func (wr *WalletRepo) CreateWallet(ctx context.Context, wallet *model.Wallet) error {
-	err := wr.db.QueryRow(ctx, "INSERT INTO wallets (user_id, currency,type) VALUES ($1, $2, $3) RETURNING id", wallet.UserID, wallet.Currency, wallet.Type).Scan(&wallet.ID)
+	err := wr.db.QueryRow(ctx, "INSERT INTO wallets (user_id, currency,type) VALUES ($1, $2, $3) RETURNING id, balance, locked_balance, created_at, updated_at", wallet.UserID, wallet.Currency, wallet.Type).
+		Scan(&wallet.ID, &wallet.Balance, &wallet.LockedBalance, &wallet.CreatedAt, &wallet.UpdatedAt)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				return ErrWalletExists
+			case "23503":
+				return ErrUserNotFound
+			}
+		}
 		return err
 	}
 	return nil
 }
+
+const walletColumns = "id, user_id, balance, locked_balance, currency, type, created_at, updated_at"
+
+func scanWallet(row pgx.Row, w *model.Wallet) error {
+	return row.Scan(&w.ID, &w.UserID, &w.Balance, &w.LockedBalance, &w.Currency, &w.Type, &w.CreatedAt, &w.UpdatedAt)
+}
+
+func (wr *WalletRepo) GetWallet(ctx context.Context, id string) (*model.Wallet, error) {
+	var w model.Wallet
+	err := scanWallet(wr.db.QueryRow(ctx, "SELECT "+walletColumns+" FROM wallets WHERE id = $1", id), &w)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWalletNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (wr *WalletRepo) ListWallets(ctx context.Context, userID, currency string) ([]model.Wallet, error) {
+	rows, err := wr.db.Query(ctx, `
+		SELECT `+walletColumns+`
+		FROM wallets
+		WHERE user_id = $1 AND ($2 = '' OR currency = $2)
+		ORDER BY currency, type`, userID, currency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wallets := []model.Wallet{}
+	for rows.Next() {
+		var w model.Wallet
+		if err := scanWallet(rows, &w); err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, w)
+	}
+	return wallets, rows.Err()
+}
+
+// GetStatement returns one page of ledger entries for the wallet together with
+// its opening and closing balance over the whole requested range. The external
+// account is debit-normal; every other wallet is credit-normal.
+func (wr *WalletRepo) GetStatement(ctx context.Context, wallet *model.Wallet, filter *StatementFilter) (*Statement, error) {
+	sign := "credit - debit"
+	if wallet.Type == "external" {
+		sign = "debit - credit"
+	}
+
+	statement := &Statement{
+		WalletID: wallet.ID.String(),
+		Currency: wallet.Currency,
+		From:     filter.From,
+		To:       filter.To,
+	}
+
+	var movement int64
+	err := wr.db.QueryRow(ctx, `
+		SELECT
+			COALESCE(SUM(`+sign+`) FILTER (WHERE created_at < $2), 0),
+			COALESCE(SUM(`+sign+`) FILTER (WHERE created_at >= $2), 0)
+		FROM ledger_entries
+		WHERE account_id = $1 AND created_at < $3`,
+		wallet.ID, filter.From, filter.To,
+	).Scan(&statement.OpeningBalance, &movement)
+	if err != nil {
+		return nil, err
+	}
+	statement.ClosingBalance = statement.OpeningBalance + movement
+
+	rows, err := wr.db.Query(ctx, `
+		SELECT id, transaction_id, account_id, debit, credit, balance_after, description, created_at, updated_at
+		FROM ledger_entries
+		WHERE account_id = $1 AND created_at >= $2 AND created_at < $3 AND id > $4
+		ORDER BY id ASC
+		LIMIT $5`,
+		wallet.ID, filter.From, filter.To, filter.AfterID, filter.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statement.Entries = []model.LedgerEntry{}
+	for rows.Next() {
+		var e model.LedgerEntry
+		if err := rows.Scan(&e.ID, &e.TransactionID, &e.AccountID, &e.Debit, &e.Credit, &e.BalanceAfter, &e.Description, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			return nil, err
+		}
+		statement.Entries = append(statement.Entries, e)
+	}
+	return statement, rows.Err()
+}
+
+// lockTTL bounds how long a crashed holder keeps a user's wallets locked; a
+// live one renews the lock.
+const lockTTL = 10 * time.Second
+
+// Lock takes the Redis lock guarding userID's wallets. Its fencing token is
+// issued above the highest fence_token stored on them, so writes carrying it
+// pass the fence_token trigger even if Redis lost the lock's counter.
+func Lock(ctx context.Context, db *pgxpool.Pool, rc *redis.Client, userID string) (*redis.Lock, error) {
+	var floor int64
+	err := db.QueryRow(ctx, "SELECT COALESCE(MAX(fence_token), 0) FROM wallets WHERE user_id = $1", userID).Scan(&floor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wallet fencing token: %w", err)
+	}
+	return rc.AcquireFencedLock(ctx, "wallet:"+userID, lockTTL, floor)
+}
+
+// FenceError returns ErrStaleFencingToken, wrapping err, if err was raised for
+// a wallet update carrying a stale fencing token, and err unchanged otherwise.
+func FenceError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == codeStaleFencingToken {
+		return fmt.Errorf("%w: %s", ErrStaleFencingToken, pgErr.Message)
+	}
+	return err
+}
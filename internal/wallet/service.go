@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/Niiaks/Aegis/internal/middleware"
 	"github.com/Niiaks/Aegis/internal/model"
@@ -22,3 +23,33 @@ func (ws *WalletService) CreateWallet(ctx context.Context, wallet *model.Wallet)
 	logger.Info().Msg("Creating wallet in service layer")
 	return ws.walletRepo.CreateWallet(ctx, wallet)
 }
+
+func (ws *WalletService) GetWallet(ctx context.Context, id string) (*model.Wallet, error) {
+	return ws.walletRepo.GetWallet(ctx, id)
+}
+
+func (ws *WalletService) ListWallets(ctx context.Context, userID, currency string) ([]model.Wallet, error) {
+	return ws.walletRepo.ListWallets(ctx, userID, currency)
+}
+
+func (ws *WalletService) GetStatement(ctx context.Context, walletID string, filter *StatementFilter) (*Statement, error) {
+	wallet, err := ws.walletRepo.GetWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra entry to know whether another page exists.
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	statement, err := ws.walletRepo.GetStatement(ctx, wallet, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(statement.Entries) > limit {
+		statement.Entries = statement.Entries[:limit]
+		statement.NextCursor = strconv.FormatInt(statement.Entries[limit-1].ID, 10)
+	}
+	return statement, nil
+}
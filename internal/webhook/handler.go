@@ -5,11 +5,14 @@ import (
 	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 
+	"github.com/Niiaks/Aegis/internal/events"
 	"github.com/Niiaks/Aegis/internal/kafka"
 	"github.com/Niiaks/Aegis/internal/middleware"
+	"github.com/Niiaks/Aegis/internal/outbox"
 	"github.com/Niiaks/Aegis/pkg/types"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -71,23 +74,52 @@ func (h *WebhookHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 
 	logger.Info().Msg("Webhook signature verified")
 
-	w.WriteHeader(http.StatusOK)
+	// Paystack retries any webhook not answered with 200, so the response is
+	// only sent once the event is stored or known to be ignored.
 	var event types.PaystackWebhookEvent
-	json.Unmarshal(body, &event)
+	if err := json.Unmarshal(body, &event); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse webhook body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 	requrestID := middleware.GetRequestID(r)
-	if event.Event == "charge.success" {
+	if partitionKey, ok := webhookPartitionKey(&event); ok {
 		// Store in outbox for reliable delivery
-		_, err = h.db.Exec(ctx, `
-			INSERT INTO transaction_outbox (event_type, payload,correlation_id, partition_key, status)
-			VALUES ($1, $2, $3, $4, $5)
-		`, kafka.EventWebhookReceived, body, requrestID, event.Data.Metadata.UserID, "pending")
-
+		err = outbox.Write(ctx, h.db, outbox.Event{
+			Type:          kafka.EventWebhookReceived,
+			Source:        events.SourceWebhooks,
+			PartitionKey:  partitionKey,
+			CorrelationID: requrestID,
+			Data:          json.RawMessage(body),
+		})
+		if errors.Is(err, events.ErrInvalidEvent) {
+			logger.Error().Err(err).Str("event", event.Event).RawJSON("body", body).Msg("Webhook does not match its schema")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
 		if err != nil {
 			logger.Error().Err(err).Msg("Failed to store webhook in outbox")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		logger.Info().Str("event", event.Event).Str("user_id", event.Data.Metadata.UserID).Msg("Webhook stored in outbox")
+		logger.Info().Str("event", event.Event).Str("partition_key", partitionKey).Msg("Webhook stored in outbox")
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// webhookPartitionKey reports whether the webhook worker handles event and, if
+// so, the key that orders it: the seller for charges, the payout transaction,
+// which is the transfer reference, for transfers and the refunded charge's
+// reference for refunds.
+func webhookPartitionKey(event *types.PaystackWebhookEvent) (string, bool) {
+	switch event.Event {
+	case "charge.success":
+		return event.Data.Metadata.UserID, true
+	case "transfer.success", "transfer.failed", "transfer.reversed":
+		return event.Data.Reference, true
+	case "refund.processed", "refund.failed":
+		return event.Data.TransactionReference, true
 	}
+	return "", false
 }
@@ -2,7 +2,18 @@ package constants
 
 type WalletType string
 
+// SystemUserID owns the system wallets and the adjustment transactions posted
+// when resolving discrepancies.
+const SystemUserID = "00000000-0000-0000-0000-000000000000"
+
 const (
 	AccountExternalID WalletType = "00000000-0000-0000-0000-000000000001"
 	AccountPlatformID WalletType = "00000000-0000-0000-0000-000000000002"
 )
+
+// SystemCurrency is the currency of the external and platform accounts.
+const SystemCurrency = "GHS"
+
+// SupportedCurrencies lists the currencies Aegis accepts payments in. Every
+// seller gets a settlement wallet per currency when onboarded.
+var SupportedCurrencies = []string{"USD", "EUR", "GHS"}
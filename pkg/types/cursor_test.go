@@ -0,0 +1,58 @@
+package types
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	id := uuid.MustParse("0b7e5c2a-3d4f-4e1a-8b9c-2d3e4f5a6b7c")
+
+	tests := []struct {
+		name      string
+		createdAt time.Time
+	}{
+		{name: "utc", createdAt: time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)},
+		{name: "nanoseconds", createdAt: time.Date(2026, 10, 18, 9, 30, 0, 123456789, time.UTC)},
+		{name: "offset zone", createdAt: time.Date(2026, 10, 18, 9, 30, 0, 0, time.FixedZone("WAT", 3600))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(Cursor{CreatedAt: tt.createdAt, ID: id}.Encode())
+			if err != nil {
+				t.Fatalf("DecodeCursor() error = %v", err)
+			}
+			if !got.CreatedAt.Equal(tt.createdAt) || got.ID != id {
+				t.Errorf("DecodeCursor() = %v %v, want %v %v", got.CreatedAt, got.ID, tt.createdAt, id)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "empty", cursor: ""},
+		{name: "not base64", cursor: "!!!"},
+		{name: "no separator", cursor: encode("2026-10-18T09:30:00Z")},
+		{name: "bad time", cursor: encode("yesterday,0b7e5c2a-3d4f-4e1a-8b9c-2d3e4f5a6b7c")},
+		{name: "bad id", cursor: encode("2026-10-18T09:30:00Z,42")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor(%q) error = %v, want %v", tt.cursor, err, ErrInvalidCursor)
+			}
+		})
+	}
+}
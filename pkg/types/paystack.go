@@ -7,19 +7,22 @@ type PaystackWebhookEvent struct {
 	Data  PaystackWebhookData `json:"data"`
 }
 type PaystackWebhookData struct {
-	ID              int64      `json:"id"`
-	Domain          string     `json:"domain"`
-	Status          string     `json:"status"`
-	Reference       string     `json:"reference"`
-	Amount          int64      `json:"amount"`
-	Message         *string    `json:"message"`
-	GatewayResponse string     `json:"gateway_response"`
-	PaidAt          *time.Time `json:"paid_at"`
-	CreatedAt       time.Time  `json:"created_at"`
-	Channel         string     `json:"channel"`
-	Currency        string     `json:"currency"`
-	IPAddress       string     `json:"ip_address"`
-	Metadata        struct {
+	ID           int64  `json:"id"`
+	Domain       string `json:"domain"`
+	Status       string `json:"status"`
+	Reference    string `json:"reference"`
+	TransferCode string `json:"transfer_code"`
+	// TransactionReference is the refunded charge's reference, on refund events.
+	TransactionReference string     `json:"transaction_reference"`
+	Amount               int64      `json:"amount"`
+	Message              *string    `json:"message"`
+	GatewayResponse      string     `json:"gateway_response"`
+	PaidAt               *time.Time `json:"paid_at"`
+	CreatedAt            time.Time  `json:"created_at"`
+	Channel              string     `json:"channel"`
+	Currency             string     `json:"currency"`
+	IPAddress            string     `json:"ip_address"`
+	Metadata             struct {
 		UserID        string `json:"user_id" validate:"required,uuid4"`
 		TransactionID string `json:"transaction_id" validate:"required,uuid4"`
 	} `json:"metadata"`
@@ -65,3 +68,124 @@ type BalanceUpdateEvent struct {
 	NetAmount     int64  `json:"net_amount"`
 	Currency      string `json:"currency"`
 }
+
+type TransferRequest struct {
+	Source    string `json:"source"`
+	Amount    int64  `json:"amount"`
+	Recipient string `json:"recipient"`
+	Reason    string `json:"reason,omitempty"`
+	Currency  string `json:"currency"`
+	Reference string `json:"reference"`
+}
+
+type TransferResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		ID           int64  `json:"id"`
+		Reference    string `json:"reference"`
+		TransferCode string `json:"transfer_code"`
+		Amount       int64  `json:"amount"`
+		Currency     string `json:"currency"`
+		Status       string `json:"status"`
+	} `json:"data"`
+}
+
+type PayoutEvent struct {
+	TransactionID string `json:"transaction_id"`
+	UserID        string `json:"user_id"`
+	WalletID      string `json:"wallet_id"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	Recipient     string `json:"recipient"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+type PayoutStatusEvent struct {
+	TransactionID string `json:"transaction_id"`
+	UserID        string `json:"user_id"`
+	Status        string `json:"status"`
+	TransferCode  string `json:"transfer_code,omitempty"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+type PaystackRefundRequest struct {
+	Transaction  string `json:"transaction"`
+	Amount       int64  `json:"amount,omitempty"`
+	Currency     string `json:"currency,omitempty"`
+	MerchantNote string `json:"merchant_note,omitempty"`
+}
+
+// PaystackRefund is a refund as returned by the create, fetch and list
+// endpoints. Status is pending, processing, processed or failed.
+type PaystackRefund struct {
+	ID       int64  `json:"id"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Status   string `json:"status"`
+}
+
+type PaystackRefundResponse struct {
+	Status  bool           `json:"status"`
+	Message string         `json:"message"`
+	Data    PaystackRefund `json:"data"`
+}
+
+type PaystackRefundListResponse struct {
+	Status  bool             `json:"status"`
+	Message string           `json:"message"`
+	Data    []PaystackRefund `json:"data"`
+}
+
+// PaystackRefundWebhookEvent is the part of a refund.* webhook Aegis reads. The
+// refund's outcome is fetched from Paystack rather than taken from the webhook.
+type PaystackRefundWebhookEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		Status               string `json:"status"`
+		TransactionReference string `json:"transaction_reference"`
+	} `json:"data"`
+}
+
+// PaystackTransaction is a charge as returned by the list and verify endpoints.
+type PaystackTransaction struct {
+	ID        int64      `json:"id"`
+	Status    string     `json:"status"`
+	Reference string     `json:"reference"`
+	Amount    int64      `json:"amount"`
+	Currency  string     `json:"currency"`
+	Fees      int64      `json:"fees"`
+	PaidAt    *time.Time `json:"paid_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type PaystackTransactionListResponse struct {
+	Status  bool                  `json:"status"`
+	Message string                `json:"message"`
+	Data    []PaystackTransaction `json:"data"`
+	Meta    struct {
+		Total     int `json:"total"`
+		Page      int `json:"page"`
+		PageCount int `json:"pageCount"`
+	} `json:"meta"`
+}
+
+type PaystackVerifyResponse struct {
+	Status  bool                `json:"status"`
+	Message string              `json:"message"`
+	Data    PaystackTransaction `json:"data"`
+}
+
+type ReconciliationJob struct {
+	RunDate string `json:"run_date"` // YYYY-MM-DD, UTC
+}
+
+type DiscrepancyDetectedEvent struct {
+	DiscrepancyID       string `json:"discrepancy_id"`
+	ReconciliationRunID string `json:"reconciliation_run_id"`
+	TransactionID       string `json:"transaction_id,omitempty"`
+	PspReference        string `json:"psp_reference,omitempty"`
+	ReasonCode          string `json:"reason_code"`
+	ExpectedAmount      int64  `json:"expected_amount"`
+	ActualAmount        int64  `json:"actual_amount"`
+}
@@ -22,3 +22,34 @@ type InitializePaymentResponse struct {
 		Reference        string `json:"reference"`
 	} `json:"data"`
 }
+
+type PayoutRequest struct {
+	UserID    string `json:"user_id" validate:"required,uuid4"`
+	Amount    int64  `json:"amount" validate:"required,gt=0"`
+	Currency  string `json:"currency" validate:"required,len=3"`
+	Recipient string `json:"recipient" validate:"required"`
+	Reason    string `json:"reason,omitempty" validate:"max=255"`
+}
+
+type PayoutResponse struct {
+	TransactionID string `json:"transaction_id"`
+	Status        string `json:"status"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+}
+
+// RefundRequest refunds a completed charge. An Amount of zero refunds
+// whatever has not been refunded yet.
+type RefundRequest struct {
+	Amount int64  `json:"amount" validate:"gte=0"`
+	Reason string `json:"reason,omitempty" validate:"max=255"`
+}
+
+type RefundResponse struct {
+	RefundID      string `json:"refund_id"`
+	TransactionID string `json:"transaction_id"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	Status        string `json:"status"`
+	RefundedTotal int64  `json:"refunded_total"`
+}
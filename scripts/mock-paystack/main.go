@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,6 +21,46 @@ type InitializePaymentResponse struct {
 	} `json:"data"`
 }
 
+type TransferResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Reference    string `json:"reference"`
+		TransferCode string `json:"transfer_code"`
+		Amount       int64  `json:"amount"`
+		Currency     string `json:"currency"`
+		Status       string `json:"status"`
+	} `json:"data"`
+}
+
+type Refund struct {
+	ID          int64  `json:"id"`
+	Transaction string `json:"transaction"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	Status      string `json:"status"`
+}
+
+type RefundResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    Refund `json:"data"`
+}
+
+// transfers remembers created transfers by reference, so verify calls find
+// them and a repeated reference is rejected as Paystack does.
+var transfers = struct {
+	sync.Mutex
+	byRef map[string]TransferResponse
+}{byRef: map[string]TransferResponse{}}
+
+// refunds remembers created refunds by ID. Like transfers, a refund is queued
+// when created and reported processed once it is fetched or listed.
+var refunds = struct {
+	sync.Mutex
+	byID map[int64]Refund
+}{byID: map[int64]Refund{}}
+
 func main() {
 	port := ":8081"
 	http.HandleFunc("/transaction/initialize", func(w http.ResponseWriter, r *http.Request) {
@@ -44,6 +87,179 @@ func main() {
 		log.Printf("Processed mock payment initialization: %s", resp.Data.Reference)
 	})
 
+	http.HandleFunc("/transfer", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Amount    int64  `json:"amount"`
+			Currency  string `json:"currency"`
+			Reference string `json:"reference"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		resp := TransferResponse{
+			Status:  true,
+			Message: "Transfer has been queued",
+		}
+		resp.Data.Reference = req.Reference
+		resp.Data.TransferCode = fmt.Sprintf("TRF_mock_%d", time.Now().UnixNano())
+		resp.Data.Amount = req.Amount
+		resp.Data.Currency = req.Currency
+		resp.Data.Status = "pending"
+
+		transfers.Lock()
+		_, duplicate := transfers.byRef[req.Reference]
+		if !duplicate {
+			transfers.byRef[req.Reference] = resp
+		}
+		transfers.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if duplicate {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"status": false, "message": "Duplicate Transfer Reference"})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+
+		log.Printf("Processed mock transfer: %s", resp.Data.TransferCode)
+	})
+
+	// Transfers succeed as soon as they are verified.
+	http.HandleFunc("/transfer/verify/", func(w http.ResponseWriter, r *http.Request) {
+		reference := strings.TrimPrefix(r.URL.Path, "/transfer/verify/")
+
+		transfers.Lock()
+		resp, ok := transfers.byRef[reference]
+		if ok {
+			resp.Message = "Transfer retrieved"
+			resp.Data.Status = "success"
+			transfers.byRef[reference] = resp
+		}
+		transfers.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"status": false, "message": "Transfer not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	http.HandleFunc("/refund", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+		case http.MethodGet:
+			transaction := r.URL.Query().Get("transaction")
+			var list []Refund
+			refunds.Lock()
+			for id, refund := range refunds.byID {
+				if refund.Transaction == transaction {
+					refund.Status = "processed"
+					refunds.byID[id] = refund
+					list = append(list, refund)
+				}
+			}
+			refunds.Unlock()
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"status":  true,
+				"message": "Refunds retrieved",
+				"data":    list,
+			})
+			return
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Transaction string `json:"transaction"`
+			Amount      int64  `json:"amount"`
+			Currency    string `json:"currency"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		resp := RefundResponse{
+			Status:  true,
+			Message: "Refund has been queued for processing",
+			Data: Refund{
+				ID:          time.Now().UnixNano(),
+				Transaction: req.Transaction,
+				Amount:      req.Amount,
+				Currency:    req.Currency,
+				Status:      "pending",
+			},
+		}
+
+		refunds.Lock()
+		refunds.byID[resp.Data.ID] = resp.Data
+		refunds.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+
+		log.Printf("Processed mock refund for: %s", req.Transaction)
+	})
+
+	http.HandleFunc("/refund/", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/refund/"), 10, 64)
+
+		refunds.Lock()
+		refund, ok := refunds.byID[id]
+		if ok {
+			refund.Status = "processed"
+			refunds.byID[id] = refund
+		}
+		refunds.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"status": false, "message": "Refund not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(RefundResponse{Status: true, Message: "Refund retrieved", Data: refund})
+	})
+
+	// The mock keeps no state, so reconciliation sees an empty transaction list
+	// and every verify lookup misses.
+	http.HandleFunc("/transaction", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":  true,
+			"message": "Transactions retrieved",
+			"data":    []any{},
+			"meta":    map[string]int{"total": 0, "page": 1, "pageCount": 1},
+		})
+	})
+
+	http.HandleFunc("/transaction/verify/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":  false,
+			"message": "Transaction reference not found",
+		})
+	})
+
 	log.Printf("Mock Paystack server starting on %s...", port)
 	if err := http.ListenAndServe(port, nil); err != nil {
 		log.Fatal(err)
@@ -1,6 +1,7 @@
 import http from 'k6/http';
 import { check, sleep } from 'k6';
 import crypto from 'k6/crypto';
+import { uuidv4 } from 'https://jslib.k6.io/k6-utils/1.4.0/index.js';
 
 export const options = {
     vus: 20, // 20 concurrent workers hitting the SAME wallet
@@ -21,7 +22,7 @@ export default function () {
             reference: `ref_${Math.random()}`,
             metadata: {
                 user_id: TARGET_USER_ID,
-                transaction_id: `tx_${Math.random()}`,
+                transaction_id: uuidv4(),
             },
         },
     });